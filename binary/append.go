@@ -0,0 +1,106 @@
+/*
+ * Copyright © 2018 Lynn <lynn9388@gmail.com>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package binary
+
+import (
+	"errors"
+	"reflect"
+)
+
+// ConsistencyProof is the list of hashes returned by MerkleTree's
+// ConsistencyProof method, named for use with SignedTreeHead-based
+// verification.
+type ConsistencyProof = [][]byte
+
+// SignedTreeHead is a CT-style commitment to a tree's state: its size and
+// root hash at that size.
+type SignedTreeHead struct {
+	Size int
+	Hash []byte
+}
+
+// VerifySignedTreeHeads verifies that first and second are consistent
+// according to proof, as returned by MerkleTree's ConsistencyProof method,
+// using SHA256. Use VerifySignedTreeHeadsWithHasher for a tree built with a
+// different Hasher.
+//
+// first.Size must be <= second.Size. This is the same check
+// VerifyConsistencyProof performs, reconstructing both tree heads from proof
+// and raw sizes/roots rather than a pair of SignedTreeHeads; pick whichever
+// shape is more convenient to the caller.
+func VerifySignedTreeHeads(first, second *SignedTreeHead, proof ConsistencyProof) bool {
+	return VerifySignedTreeHeadsWithHasher(SHA256, first, second, proof)
+}
+
+// VerifySignedTreeHeadsWithHasher verifies a consistency proof produced by a
+// tree built with hasher. See VerifySignedTreeHeads.
+func VerifySignedTreeHeadsWithHasher(hasher Hasher, first, second *SignedTreeHead, proof ConsistencyProof) bool {
+	return VerifyConsistencyProofWithHasher(hasher, first.Size, second.Size, first.Hash, second.Hash, proof)
+}
+
+// Append grows the tree by one leaf in place, returning the tree's new
+// root. It only rebuilds the nodes on the path from the new leaf to the
+// root, in O(log n), rather than calling New again over every leaf. mt must
+// be the root returned by New, NewWithOptions, or a previous call to
+// Append; mt and its descendants must not be used afterwards except
+// through the value Append returns.
+//
+// Append only supports incremental growth of a tree built WithLayout(
+// LayoutRFC6962) (the default); it returns an error for any other layout,
+// since only LayoutRFC6962's power-of-two split guarantees that growing
+// the tree by one leaf never needs to touch more than its right spine.
+func (mt *MerkleTree) Append(data []byte) (*MerkleTree, error) {
+	if reflect.ValueOf(mt.layout).Pointer() != reflect.ValueOf(LayoutRFC6962).Pointer() {
+		return nil, errors.New("binary: Append only supports a tree built WithLayout(LayoutRFC6962)")
+	}
+
+	leaf := &MerkleTree{Hash: mt.hasher.HashLeaf(data)}
+
+	root := mt
+	if n := len(mt.leaves); n > 0 {
+		root = appendChild(mt.hasher, mt, n, leaf)
+	} else {
+		root = leaf
+	}
+
+	root.hasher = mt.hasher
+	root.layout = mt.layout
+	root.leaves = append(mt.leaves, leaf.Hash)
+	return root, nil
+}
+
+// appendChild grows the subtree rooted at node, which covers size leaves,
+// by attaching leaf immediately after it, mutating node's descendants in
+// place, and returns the new root of the combined subtree. It mirrors the
+// split New uses to build a tree of size+1 leaves from scratch, but only
+// touches the nodes on node's right spine.
+func appendChild(hasher Hasher, node *MerkleTree, size int, leaf *MerkleTree) *MerkleTree {
+	if size&(size-1) == 0 {
+		// node is itself a complete power-of-two block, so New would put it
+		// whole on the left and the new leaf on the right.
+		parent := &MerkleTree{Left: node, Right: leaf, Hash: hasher.HashChildren(node.Hash, leaf.Hash)}
+		node.Parent = parent
+		leaf.Parent = parent
+		return parent
+	}
+
+	k := splitPoint(size)
+	node.Right = appendChild(hasher, node.Right, size-k, leaf)
+	node.Right.Parent = node
+	node.Hash = hasher.HashChildren(node.Left.Hash, node.Right.Hash)
+	return node
+}
@@ -0,0 +1,132 @@
+/*
+ * Copyright © 2018 Lynn <lynn9388@gmail.com>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package binary
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestMerkleTree_Append(t *testing.T) {
+	for n := 0; n < 20; n++ {
+		data := make([][]byte, n)
+		for i := range data {
+			data[i] = []byte{byte(i)}
+		}
+
+		mt := New()
+		for i, datum := range data {
+			var err error
+			mt, err = mt.Append(datum)
+			if err != nil {
+				t.Fatalf("n=%d: Append(%d) error = %v", n, i, err)
+			}
+			want := New(data[:i+1]...)
+
+			if !bytes.Equal(mt.Hash, want.Hash) {
+				t.Fatalf("n=%d: after appending leaf %d, Hash = %x, want %x", n, i, mt.Hash, want.Hash)
+			}
+			if mt.LeafCount() != i+1 {
+				t.Fatalf("n=%d: after appending leaf %d, LeafCount() = %d, want %d", n, i, mt.LeafCount(), i+1)
+			}
+
+			for j := 0; j <= i; j++ {
+				ap, err := mt.GetAuditPath(data[j])
+				if err != nil {
+					t.Fatalf("n=%d: GetAuditPath(%d) error = %v", n, j, err)
+				}
+				if !ap.IsValid(data[j], mt.Hash) {
+					t.Errorf("n=%d: AuditPath for leaf %d invalid after appending leaf %d", n, j, i)
+				}
+			}
+		}
+	}
+}
+
+func TestMerkleTree_AppendWithOptions(t *testing.T) {
+	data := [][]byte{[]byte("a"), []byte("b"), []byte("c")}
+	mt := NewWithOptions([]Option{WithHasher(RFC6962)})
+	for _, datum := range data {
+		var err error
+		mt, err = mt.Append(datum)
+		if err != nil {
+			t.Fatalf("Append() error = %v", err)
+		}
+	}
+	want := NewWithOptions([]Option{WithHasher(RFC6962)}, data...)
+	if !bytes.Equal(mt.Hash, want.Hash) {
+		t.Errorf("Append() with RFC6962 hasher = %x, want %x", mt.Hash, want.Hash)
+	}
+}
+
+func TestMerkleTree_AppendRejectsOtherLayouts(t *testing.T) {
+	mt := NewWithOptions([]Option{WithLayout(LayoutBalanced)}, []byte("a"), []byte("b"))
+	if _, err := mt.Append([]byte("c")); err == nil {
+		t.Error("Append() on a LayoutBalanced tree expected error, got nil")
+	}
+}
+
+func TestVerifySignedTreeHeads(t *testing.T) {
+	data := make([][]byte, 13)
+	for i := range data {
+		data[i] = []byte{byte(i)}
+	}
+	mt := New(data...)
+
+	for m := 0; m <= len(data); m++ {
+		t.Run("", func(t *testing.T) {
+			first := &SignedTreeHead{Size: m, Hash: mth(mt.hasher, mt.layout, mt.leaves[:m])}
+			second := &SignedTreeHead{Size: len(data), Hash: mt.Hash}
+
+			proof, err := mt.ConsistencyProof(m, len(data))
+			if err != nil {
+				t.Fatalf("ConsistencyProof(%d, %d) error = %v", m, len(data), err)
+			}
+			if !VerifySignedTreeHeads(first, second, proof) {
+				t.Errorf("VerifySignedTreeHeads(%d, %d) = false, want true", m, len(data))
+			}
+		})
+	}
+}
+
+func TestVerifySignedTreeHeads_Invalid(t *testing.T) {
+	data := make([][]byte, 8)
+	for i := range data {
+		data[i] = []byte{byte(i)}
+	}
+	mt := New(data...)
+
+	m, n := 5, 8
+	second := &SignedTreeHead{Size: n, Hash: mt.Hash}
+	proof, _ := mt.ConsistencyProof(m, n)
+
+	wrong := &SignedTreeHead{Size: m, Hash: hash([]byte("wrong"))}
+	if VerifySignedTreeHeads(wrong, second, proof) {
+		t.Error("VerifySignedTreeHeads() = true for a wrong old root, want false")
+	}
+}
+
+func TestRFC6962Hasher(t *testing.T) {
+	leaf := RFC6962.HashLeaf([]byte("a"))
+	node := RFC6962.HashChildren(leaf, leaf)
+	if bytes.Equal(leaf, node) {
+		t.Error("RFC6962 leaf and node hashes of the same input collide, want domain separation")
+	}
+	if bytes.Equal(leaf, SHA256.HashLeaf([]byte("a"))) {
+		t.Error("RFC6962.HashLeaf() matches plain SHA256, want the 0x00 prefix to change the result")
+	}
+}
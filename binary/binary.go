@@ -19,7 +19,6 @@ package binary
 
 import (
 	"bytes"
-	"crypto/sha256"
 	"encoding/hex"
 	"errors"
 	"math"
@@ -37,6 +36,22 @@ type MerkleTree struct {
 	Left   *MerkleTree
 	Right  *MerkleTree
 	Hash   []byte
+
+	// hasher is the Hasher the tree was built with; it is only populated on
+	// the root returned by New, and is used to locate leaves and build
+	// audit paths, consistency proofs and multiproofs against this tree.
+	// The verifiers do not read it; they take their own Hasher.
+	hasher Hasher
+
+	// layout is the TreeLayout the tree was built with; it is only
+	// populated on the root returned by New, and is used by
+	// ConsistencyProof to replay how ranges were split.
+	layout TreeLayout
+
+	// leaves holds the leaf hashes in their original order. It is only
+	// populated on the root returned by New, so that ConsistencyProof can
+	// recompute the hash of any prefix of the leaf sequence.
+	leaves [][]byte
 }
 
 // AuditPath is the shortest list of additional nodes in the Merkle tree
@@ -46,21 +61,36 @@ type AuditPath struct {
 	Order []int
 }
 
-func hash(data []byte) []byte {
-	hash := sha256.Sum256(data)
-	return hash[:]
-}
-
 func hashString(hash []byte) string {
 	return hex.EncodeToString(hash)
 }
 
-// New builds a new Merkle hash tree using the data. If the date is empty
-// then the hash value of the root node is the hash of an empty string.
+// splitPoint returns the largest power of two strictly less than n, the
+// point at which a range of n > 1 leaves is split into its left and right
+// subtrees.
+func splitPoint(n int) int {
+	return int(math.Exp2(math.Ceil(math.Log2(float64(n)) - 1)))
+}
+
+// New builds a new Merkle hash tree using the data, hashed with SHA256. If
+// the data is empty then the hash value of the root node is the hash of an
+// empty string. Use NewWithOptions to build one with a different Hasher.
 func New(data ...[]byte) *MerkleTree {
+	return NewWithOptions(nil, data...)
+}
+
+// NewWithOptions builds a new Merkle hash tree using the data, as
+// configured by opts (see WithHasher). It defaults to SHA256 when no
+// options are given.
+func NewWithOptions(opts []Option, data ...[]byte) *MerkleTree {
+	o := &options{hasher: SHA256, layout: LayoutRFC6962}
+	for _, opt := range opts {
+		opt(o)
+	}
+
 	n := len(data)
 	if n == 0 {
-		return &MerkleTree{Hash: hash([]byte{})}
+		return &MerkleTree{Hash: o.hasher.HashLeaf([]byte{}), hasher: o.hasher, layout: o.layout}
 	}
 	var subTree func([][]byte) *MerkleTree
 	subTree = func(data [][]byte) *MerkleTree {
@@ -68,11 +98,11 @@ func New(data ...[]byte) *MerkleTree {
 
 		// leaf node
 		if n == 1 {
-			return &MerkleTree{Hash: hash(data[0])}
+			return &MerkleTree{Hash: o.hasher.HashLeaf(data[0])}
 		}
 
 		parent := &MerkleTree{}
-		k := int(math.Exp2(math.Ceil(math.Log2(float64(n)) - 1)))
+		k := o.layout(n)
 
 		left := subTree(data[0:k])
 		right := subTree(data[k:n])
@@ -81,11 +111,151 @@ func New(data ...[]byte) *MerkleTree {
 
 		parent.Left = left
 		parent.Right = right
-		parent.Hash = hash(append(left.Hash, right.Hash...))
+		parent.Hash = o.hasher.HashChildren(left.Hash, right.Hash)
 
 		return parent
 	}
-	return subTree(data)
+
+	leaves := make([][]byte, n)
+	for i, datum := range data {
+		leaves[i] = o.hasher.HashLeaf(datum)
+	}
+
+	root := subTree(data)
+	root.hasher = o.hasher
+	root.layout = o.layout
+	root.leaves = leaves
+	return root
+}
+
+// LeafCount returns the number of leaves the tree was built from.
+func (mt *MerkleTree) LeafCount() int {
+	return len(mt.leaves)
+}
+
+// LeafHashAt returns the hash of the i-th leaf in the tree, in the order
+// the tree was built.
+func (mt *MerkleTree) LeafHashAt(i int) ([]byte, error) {
+	if i < 0 || i >= len(mt.leaves) {
+		return nil, errors.New("leaf index out of range")
+	}
+	return mt.leaves[i], nil
+}
+
+// mth (Merkle Tree Hash) recomputes the hash of a sequence of leaf hashes,
+// splitting it the same way New does. It lets ConsistencyProof compute the
+// hash of an arbitrary prefix of the leaf sequence, not just the subtrees
+// that happen to be materialized as nodes.
+func mth(hasher Hasher, layout TreeLayout, leafHashes [][]byte) []byte {
+	n := len(leafHashes)
+	if n == 0 {
+		return hasher.HashLeaf([]byte{})
+	}
+	if n == 1 {
+		return leafHashes[0]
+	}
+	k := layout(n)
+	return hasher.HashChildren(mth(hasher, layout, leafHashes[:k]), mth(hasher, layout, leafHashes[k:]))
+}
+
+// subProof implements the RFC 6962 SUBPROOF algorithm: it returns the list
+// of hashes a verifier needs, in order, to recompute both MTH(d[:m]) and
+// MTH(d). b is true while the two trees have not yet diverged from a
+// common left-aligned prefix.
+func subProof(hasher Hasher, layout TreeLayout, m int, d [][]byte, b bool) [][]byte {
+	n := len(d)
+	if m == n {
+		if b {
+			return [][]byte{}
+		}
+		return [][]byte{mth(hasher, layout, d)}
+	}
+
+	k := layout(n)
+	if m <= k {
+		return append(subProof(hasher, layout, m, d[:k], b), mth(hasher, layout, d[k:]))
+	}
+	return append(subProof(hasher, layout, m-k, d[k:], false), mth(hasher, layout, d[:k]))
+}
+
+// ConsistencyProof returns the list of hashes proving that the root of the
+// first m leaves is consistent with the root of the first n leaves, i.e.
+// that the tree of size m is a prefix of the tree of size n. m and n must
+// both be within [0, mt.LeafCount()].
+func (mt *MerkleTree) ConsistencyProof(m, n int) ([][]byte, error) {
+	if m < 0 || n < m || n > len(mt.leaves) {
+		return nil, errors.New("invalid tree sizes")
+	}
+	if m == 0 || m == n {
+		return [][]byte{}, nil
+	}
+	return subProof(mt.hasher, mt.layout, m, mt.leaves[:n], true), nil
+}
+
+// foldConsistency mirrors subProof's recursion to fold a consistency proof
+// back into the old and new root hashes it commits to.
+func foldConsistency(hasher Hasher, layout TreeLayout, m, n int, b bool, oldRoot []byte, proof [][]byte) (oldHash, newHash []byte, rest [][]byte, ok bool) {
+	if m == n {
+		if b {
+			return oldRoot, oldRoot, proof, true
+		}
+		if len(proof) == 0 {
+			return nil, nil, nil, false
+		}
+		return proof[0], proof[0], proof[1:], true
+	}
+
+	k := layout(n)
+	if m <= k {
+		old, new, rest, ok := foldConsistency(hasher, layout, m, k, b, oldRoot, proof)
+		if !ok || len(rest) == 0 {
+			return nil, nil, nil, false
+		}
+		return old, hasher.HashChildren(new, rest[0]), rest[1:], true
+	}
+
+	old, new, rest, ok := foldConsistency(hasher, layout, m-k, n-k, false, oldRoot, proof)
+	if !ok || len(rest) == 0 {
+		return nil, nil, nil, false
+	}
+	left := rest[0]
+	return hasher.HashChildren(left, old), hasher.HashChildren(left, new), rest[1:], true
+}
+
+// VerifyConsistencyProof verifies that oldRoot (the root hash of the first
+// m leaves) and newRoot (the root hash of the first n leaves) are
+// consistent according to proof, as returned by ConsistencyProof, using
+// SHA256 and LayoutRFC6962. Use VerifyConsistencyProofWithHasher for a tree
+// built with a different Hasher, or VerifyConsistencyProofWithOptions for
+// one built with a different TreeLayout too.
+func VerifyConsistencyProof(m, n int, oldRoot, newRoot []byte, proof [][]byte) bool {
+	return VerifyConsistencyProofWithHasher(SHA256, m, n, oldRoot, newRoot, proof)
+}
+
+// VerifyConsistencyProofWithHasher verifies a consistency proof produced by
+// a tree built with hasher and LayoutRFC6962. See VerifyConsistencyProof.
+func VerifyConsistencyProofWithHasher(hasher Hasher, m, n int, oldRoot, newRoot []byte, proof [][]byte) bool {
+	return VerifyConsistencyProofWithOptions(hasher, LayoutRFC6962, m, n, oldRoot, newRoot, proof)
+}
+
+// VerifyConsistencyProofWithOptions verifies a consistency proof produced
+// by a tree built with hasher and layout. See VerifyConsistencyProof.
+func VerifyConsistencyProofWithOptions(hasher Hasher, layout TreeLayout, m, n int, oldRoot, newRoot []byte, proof [][]byte) bool {
+	if m < 0 || n < m {
+		return false
+	}
+	if m == 0 {
+		return true
+	}
+	if m == n {
+		return len(proof) == 0 && bytes.Equal(oldRoot, newRoot)
+	}
+
+	old, new, rest, ok := foldConsistency(hasher, layout, m, n, true, oldRoot, proof)
+	if !ok || len(rest) != 0 {
+		return false
+	}
+	return bytes.Equal(old, oldRoot) && bytes.Equal(new, newRoot)
 }
 
 // findleaf finds the leaf node with the same hash value. If not find then
@@ -109,7 +279,7 @@ func (mt *MerkleTree) findLeaf(hash []byte) *MerkleTree {
 // GetAuditPath returns a Merkle audit path for a leaf node. The audit path
 // proofs the hash value of the data belongs to a leaf node.
 func (mt *MerkleTree) GetAuditPath(data []byte) (*AuditPath, error) {
-	node := mt.findLeaf(hash(data))
+	node := mt.findLeaf(mt.hasher.HashLeaf(data))
 	if node == nil {
 		return nil, errors.New("failed to find leaf node")
 	}
@@ -129,18 +299,26 @@ func (mt *MerkleTree) GetAuditPath(data []byte) (*AuditPath, error) {
 }
 
 // IsValid checks if an audit path is valid (the data's hash is a leaf of
-// the Merkle hash tree).
+// the Merkle hash tree), using SHA256. Use IsValidWithHasher to verify an
+// audit path produced by a tree built with a different Hasher.
 func (ap *AuditPath) IsValid(data []byte, rootHash []byte) bool {
+	return ap.IsValidWithHasher(SHA256, data, rootHash)
+}
+
+// IsValidWithHasher checks if an audit path is valid (the data's hash is a
+// leaf of the Merkle hash tree), using hasher to recompute intermediate
+// hashes.
+func (ap *AuditPath) IsValidWithHasher(hasher Hasher, data []byte, rootHash []byte) bool {
 	if ap == nil {
 		return false
 	}
 
-	h := hash(data)
+	h := hasher.HashLeaf(data)
 	for i, p := range ap.Path {
 		if ap.Order[i] == left {
-			h = hash(append(p, h...))
+			h = hasher.HashChildren(p, h)
 		} else {
-			h = hash(append(h, p...))
+			h = hasher.HashChildren(h, p)
 		}
 	}
 	return bytes.Equal(rootHash, h)
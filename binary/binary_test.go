@@ -18,10 +18,17 @@
 package binary
 
 import (
+	"bytes"
+	"crypto/sha256"
 	"reflect"
 	"testing"
 )
 
+func hash(data []byte) []byte {
+	h := sha256.Sum256(data)
+	return h[:]
+}
+
 func TestNew1(t *testing.T) {
 	type args struct {
 		data [][]byte
@@ -31,13 +38,19 @@ func TestNew1(t *testing.T) {
 		args args
 		want *MerkleTree
 	}{
-		{name: "empty0", want: &MerkleTree{Hash: hash([]byte{})}},
-		{"empty1", args{}, &MerkleTree{Hash: hash([]byte{})}},
-		{"empty2", args{[][]byte{[]byte("")}}, &MerkleTree{Hash: hash([]byte{})}},
+		{name: "empty0", want: &MerkleTree{Hash: hash([]byte{}), hasher: SHA256}},
+		{"empty1", args{}, &MerkleTree{Hash: hash([]byte{}), hasher: SHA256}},
+		{"empty2", args{[][]byte{[]byte("")}}, &MerkleTree{Hash: hash([]byte{}), hasher: SHA256, leaves: [][]byte{hash([]byte{})}}},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			if got := New(tt.args.data...); !reflect.DeepEqual(got, tt.want) {
+			got := New(tt.args.data...)
+			if got.layout == nil {
+				t.Fatal("New() left layout nil, want LayoutRFC6962")
+			}
+			gotCopy := *got
+			gotCopy.layout = nil
+			if !reflect.DeepEqual(&gotCopy, tt.want) {
 				t.Errorf("New() = %v, want %v", got, tt.want)
 			}
 		})
@@ -137,6 +150,196 @@ func TestAuditPath_IsValid(t *testing.T) {
 	}
 }
 
+func TestMerkleTree_ConsistencyProof(t *testing.T) {
+	data := make([][]byte, 13)
+	for i := range data {
+		data[i] = []byte{byte(i)}
+	}
+	mt := New(data...)
+
+	for m := 0; m <= len(data); m++ {
+		t.Run("", func(t *testing.T) {
+			oldRoot := mth(mt.hasher, mt.layout, mt.leaves[:m])
+			proof, err := mt.ConsistencyProof(m, len(data))
+			if err != nil {
+				t.Fatalf("ConsistencyProof(%d, %d) error = %v", m, len(data), err)
+			}
+			if !VerifyConsistencyProof(m, len(data), oldRoot, mt.Hash, proof) {
+				t.Errorf("VerifyConsistencyProof(%d, %d) = false, want true", m, len(data))
+			}
+		})
+	}
+
+	if _, err := mt.ConsistencyProof(-1, len(data)); err == nil {
+		t.Error("ConsistencyProof(-1, n) expected error")
+	}
+	if _, err := mt.ConsistencyProof(3, len(data)+1); err == nil {
+		t.Error("ConsistencyProof(m, n) with n > LeafCount() expected error")
+	}
+}
+
+func TestVerifyConsistencyProof_Invalid(t *testing.T) {
+	data := make([][]byte, 8)
+	for i := range data {
+		data[i] = []byte{byte(i)}
+	}
+	mt := New(data...)
+
+	m, n := 5, 8
+	oldRoot := mth(mt.hasher, mt.layout, mt.leaves[:m])
+	proof, _ := mt.ConsistencyProof(m, n)
+
+	if VerifyConsistencyProof(m, n, hash([]byte("wrong")), mt.Hash, proof) {
+		t.Error("VerifyConsistencyProof() = true for a wrong old root, want false")
+	}
+	if len(proof) > 0 && VerifyConsistencyProof(m, n, oldRoot, mt.Hash, proof[:len(proof)-1]) {
+		t.Error("VerifyConsistencyProof() = true for a truncated proof, want false")
+	}
+}
+
+func TestNewWithOptions(t *testing.T) {
+	data := [][]byte{[]byte("a"), []byte("b"), []byte("c")}
+
+	for _, hasher := range []Hasher{SHA256, SHA512_256, BLAKE2b, Keccak256} {
+		mt := NewWithOptions([]Option{WithHasher(hasher)}, data...)
+		ap, err := mt.GetAuditPath(data[1])
+		if err != nil {
+			t.Fatalf("GetAuditPath() error = %v", err)
+		}
+		if !ap.IsValidWithHasher(hasher, data[1], mt.Hash) {
+			t.Errorf("IsValidWithHasher() = false, want true")
+		}
+		if ap.IsValid(data[1], mt.Hash) && hasher != SHA256 {
+			t.Errorf("IsValid() = true for a tree built with a different Hasher, want false")
+		}
+	}
+}
+
+func TestNewWithOptions_Layout(t *testing.T) {
+	data := make([][]byte, 5)
+	for i := range data {
+		data[i] = []byte{byte(i)}
+	}
+
+	rfc := NewWithOptions([]Option{WithLayout(LayoutRFC6962)}, data...)
+	balanced := NewWithOptions([]Option{WithLayout(LayoutBalanced)}, data...)
+	if bytes.Equal(rfc.Hash, balanced.Hash) {
+		t.Fatal("LayoutRFC6962 and LayoutBalanced produced the same root for n=5, want different split shapes")
+	}
+	if rfc.Left.leafCountAt() != 4 || balanced.Left.leafCountAt() != 3 {
+		t.Errorf("left subtree sizes = %d, %d, want 4, 3", rfc.Left.leafCountAt(), balanced.Left.leafCountAt())
+	}
+
+	// ConsistencyProof relies on every snapshot size having an aligned node
+	// boundary in every larger snapshot, which only LayoutRFC6962's
+	// power-of-two split guarantees (see LayoutBalanced's doc comment); exercise the
+	// round trip WithOptions using that layout explicitly instead of
+	// assuming it generalizes to LayoutBalanced.
+	for m := 0; m <= len(data); m++ {
+		oldRoot := NewWithOptions([]Option{WithLayout(LayoutRFC6962)}, data[:m]...).Hash
+		proof, err := rfc.ConsistencyProof(m, len(data))
+		if err != nil {
+			t.Fatalf("ConsistencyProof(%d, %d) error = %v", m, len(data), err)
+		}
+		if !VerifyConsistencyProofWithOptions(SHA256, LayoutRFC6962, m, len(data), oldRoot, rfc.Hash, proof) {
+			t.Errorf("VerifyConsistencyProofWithOptions(%d, %d) = false, want true", m, len(data))
+		}
+	}
+
+	ap, err := balanced.GetAuditPath(data[4])
+	if err != nil {
+		t.Fatalf("GetAuditPath() error = %v", err)
+	}
+	if !ap.IsValid(data[4], balanced.Hash) {
+		t.Error("IsValid() = false for a LayoutBalanced tree, want true")
+	}
+
+	datas := [][]byte{data[0], data[4]}
+	mp, err := balanced.GetMultiProof(datas...)
+	if err != nil {
+		t.Fatalf("GetMultiProof() error = %v", err)
+	}
+	if !mp.IsValidWithOptions(SHA256, LayoutBalanced, datas, balanced.Hash) {
+		t.Error("IsValidWithOptions() = false for a LayoutBalanced tree, want true")
+	}
+	if mp.IsValidWithOptions(SHA256, LayoutRFC6962, datas, balanced.Hash) {
+		t.Error("IsValidWithOptions() = true against a mismatched layout, want false")
+	}
+}
+
+func TestMerkleTree_MultiProof(t *testing.T) {
+	data := [][]byte{[]byte("a"), []byte("b"), []byte("c"), []byte("d"), []byte("e")}
+	mt := New(data...)
+
+	datas := [][]byte{data[0], data[3]}
+	mp, err := mt.GetMultiProof(datas...)
+	if err != nil {
+		t.Fatalf("GetMultiProof() error = %v", err)
+	}
+	if !reflect.DeepEqual(mp.Indices, []int{0, 3}) {
+		t.Errorf("GetMultiProof().Indices = %v, want [0 3]", mp.Indices)
+	}
+	if !VerifyMultiProof(datas, mp, mt.Hash) {
+		t.Error("VerifyMultiProof() = false, want true")
+	}
+	if VerifyMultiProof([][]byte{data[1], data[3]}, mp, mt.Hash) {
+		t.Error("VerifyMultiProof() = true for mismatched data, want false")
+	}
+	if !mp.IsValid(datas, mt.Hash) {
+		t.Error("IsValid() = false, want true")
+	}
+	if mp.IsValid([][]byte{data[1], data[3]}, mt.Hash) {
+		t.Error("IsValid() = true for mismatched data, want false")
+	}
+
+	roundTripped, err := DeserializeBinary(mp.SerializeBinary())
+	if err != nil {
+		t.Fatalf("DeserializeBinary() error = %v", err)
+	}
+	if !reflect.DeepEqual(mp, roundTripped) {
+		t.Errorf("DeserializeBinary(SerializeBinary()) = %v, want %v", roundTripped, mp)
+	}
+
+	if _, err := mt.GetMultiProof(); err == nil {
+		t.Error("GetMultiProof() with no data expected error")
+	}
+	if _, err := mt.GetMultiProof([]byte("missing")); err == nil {
+		t.Error("GetMultiProof() with unknown data expected error")
+	}
+}
+
+func TestMerkleTree_MultiProofAllLeaves(t *testing.T) {
+	data := make([][]byte, 13)
+	for i := range data {
+		data[i] = []byte{byte(i)}
+	}
+	mt := New(data...)
+
+	for m := 1; m <= len(data); m++ {
+		datas := data[:m]
+		mp, err := mt.GetMultiProof(datas...)
+		if err != nil {
+			t.Fatalf("GetMultiProof(%d leaves) error = %v", m, err)
+		}
+		if !VerifyMultiProof(datas, mp, mt.Hash) {
+			t.Errorf("VerifyMultiProof(%d leaves) = false, want true", m)
+		}
+	}
+}
+
+func TestMerkleTree_LeafHashAt(t *testing.T) {
+	mt := New([]byte("a"), []byte("b"), []byte("c"))
+	if got, _ := mt.LeafHashAt(1); !reflect.DeepEqual(got, hash([]byte("b"))) {
+		t.Errorf("LeafHashAt(1) = %x, want %x", got, hash([]byte("b")))
+	}
+	if _, err := mt.LeafHashAt(3); err == nil {
+		t.Error("LeafHashAt(3) expected error for out of range index")
+	}
+	if mt.LeafCount() != 3 {
+		t.Errorf("LeafCount() = %v, want 3", mt.LeafCount())
+	}
+}
+
 func TestMerkleTree_Pretty(t *testing.T) {
 	aHash := hash([]byte("a"))
 	bHash := hash([]byte("b"))
@@ -0,0 +1,162 @@
+/*
+ * Copyright © 2018 Lynn <lynn9388@gmail.com>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package binary
+
+import (
+	"crypto/sha256"
+	"crypto/sha512"
+
+	"golang.org/x/crypto/blake2b"
+	"golang.org/x/crypto/sha3"
+)
+
+// Hasher computes the leaf and internal node hashes of a MerkleTree.
+// HashLeaf and HashChildren are kept distinct so implementations can apply
+// their own domain separation between leaves and internal nodes.
+type Hasher interface {
+	Size() int
+	HashLeaf(data []byte) []byte
+	HashChildren(left, right []byte) []byte
+}
+
+type sha256Hasher struct{}
+
+func (sha256Hasher) Size() int { return sha256.Size }
+
+func (sha256Hasher) HashLeaf(data []byte) []byte {
+	h := sha256.Sum256(data)
+	return h[:]
+}
+
+func (sha256Hasher) HashChildren(left, right []byte) []byte {
+	h := sha256.Sum256(concatBytes(left, right))
+	return h[:]
+}
+
+// SHA256 is the default Hasher, matching the algorithm New has always used.
+var SHA256 Hasher = sha256Hasher{}
+
+type sha512256Hasher struct{}
+
+func (sha512256Hasher) Size() int { return sha512.Size256 }
+
+func (sha512256Hasher) HashLeaf(data []byte) []byte {
+	h := sha512.Sum512_256(data)
+	return h[:]
+}
+
+func (sha512256Hasher) HashChildren(left, right []byte) []byte {
+	h := sha512.Sum512_256(concatBytes(left, right))
+	return h[:]
+}
+
+// SHA512_256 is a Hasher backed by SHA-512/256.
+var SHA512_256 Hasher = sha512256Hasher{}
+
+type blake2bHasher struct{}
+
+func (blake2bHasher) Size() int { return blake2b.Size256 }
+
+func (blake2bHasher) HashLeaf(data []byte) []byte {
+	h := blake2b.Sum256(data)
+	return h[:]
+}
+
+func (blake2bHasher) HashChildren(left, right []byte) []byte {
+	h := blake2b.Sum256(concatBytes(left, right))
+	return h[:]
+}
+
+// BLAKE2b is a Hasher backed by BLAKE2b-256, as used by Substrate/Polkadot
+// style commitments.
+var BLAKE2b Hasher = blake2bHasher{}
+
+type keccak256Hasher struct{}
+
+func (keccak256Hasher) Size() int { return 32 }
+
+func (keccak256Hasher) HashLeaf(data []byte) []byte {
+	h := sha3.NewLegacyKeccak256()
+	h.Write(data)
+	return h.Sum(nil)
+}
+
+func (keccak256Hasher) HashChildren(left, right []byte) []byte {
+	h := sha3.NewLegacyKeccak256()
+	h.Write(left)
+	h.Write(right)
+	return h.Sum(nil)
+}
+
+// Keccak256 is a Hasher backed by Keccak-256, as used by Ethereum style
+// commitments.
+var Keccak256 Hasher = keccak256Hasher{}
+
+// rfc6962Hasher is a Hasher that applies the RFC 6962 domain separation
+// prefixes (0x00 for leaves, 0x01 for internal nodes) around SHA256, so a
+// MerkleTree built WithHasher(RFC6962) is interoperable with Certificate
+// Transparency logs.
+type rfc6962Hasher struct{}
+
+func (rfc6962Hasher) Size() int { return sha256.Size }
+
+func (rfc6962Hasher) HashLeaf(data []byte) []byte {
+	h := sha256.New()
+	h.Write([]byte{0x00})
+	h.Write(data)
+	return h.Sum(nil)
+}
+
+func (rfc6962Hasher) HashChildren(left, right []byte) []byte {
+	h := sha256.New()
+	h.Write([]byte{0x01})
+	h.Write(left)
+	h.Write(right)
+	return h.Sum(nil)
+}
+
+// RFC6962 is a Hasher backed by SHA256 with RFC 6962 domain separation. See
+// the rfc6962 package for a standalone, non-pluggable implementation of the
+// same scheme.
+var RFC6962 Hasher = rfc6962Hasher{}
+
+func concatBytes(a, b []byte) []byte {
+	buf := make([]byte, 0, len(a)+len(b))
+	buf = append(buf, a...)
+	buf = append(buf, b...)
+	return buf
+}
+
+// Option configures a MerkleTree built with NewWithOptions.
+type Option func(*options)
+
+type options struct {
+	hasher Hasher
+	layout TreeLayout
+}
+
+// WithHasher sets the Hasher a MerkleTree is built and verified with. The
+// default is SHA256.
+func WithHasher(hasher Hasher) Option {
+	return func(o *options) { o.hasher = hasher }
+}
+
+// WithLayout sets the TreeLayout a MerkleTree is built and verified with.
+// The default is LayoutRFC6962.
+func WithLayout(layout TreeLayout) Option {
+	return func(o *options) { o.layout = layout }
+}
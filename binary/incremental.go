@@ -0,0 +1,270 @@
+/*
+ * Copyright © 2018 Lynn <lynn9388@gmail.com>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package binary
+
+import (
+	"errors"
+	"runtime"
+	"sync"
+)
+
+// Node is the pair of child hashes stored for one internal node of an
+// IncrementalTree, keyed by the node's own hash.
+type Node struct {
+	Left, Right []byte
+}
+
+// Storage persists the internal nodes of an IncrementalTree. Because the
+// tree is content-addressed and append-only, Put is only ever called
+// with a hash Get has never returned a Node for, so a Storage never needs
+// to support overwriting or removing an existing entry.
+type Storage interface {
+	Get(hash []byte) (Node, error)
+	Put(hash []byte, node Node) error
+}
+
+// ErrNodeNotFound is returned by a Storage's Get for a hash it holds no
+// Node for.
+var ErrNodeNotFound = errors.New("binary: node not found")
+
+// MemStorage is an in-memory Storage backed by a map. It is the default
+// NewIncrementalTree uses when no other Storage is given, and is useful
+// on its own for trees small enough to keep entirely in memory.
+type MemStorage struct {
+	nodes map[string]Node
+}
+
+// NewMemStorage creates an empty in-memory Storage.
+func NewMemStorage() *MemStorage {
+	return &MemStorage{nodes: make(map[string]Node)}
+}
+
+// Get implements Storage.
+func (s *MemStorage) Get(hash []byte) (Node, error) {
+	node, ok := s.nodes[string(hash)]
+	if !ok {
+		return Node{}, ErrNodeNotFound
+	}
+	return node, nil
+}
+
+// Put implements Storage.
+func (s *MemStorage) Put(hash []byte, node Node) error {
+	s.nodes[string(hash)] = node
+	return nil
+}
+
+// RootHash is the hash committing to an IncrementalTree's current state.
+type RootHash []byte
+
+// minLeafsThreshold is the smallest AddBatch call for which hashing
+// leaves in parallel pays for the extra bookkeeping; smaller batches fall
+// back to Add.
+const minLeafsThreshold = 64
+
+// IncrementalTree is a LayoutRFC6962 Merkle tree whose internal nodes live
+// in a Storage instead of as in-memory pointers, so a tree with far more
+// nodes than fit in memory can still be grown one leaf or one batch at a
+// time. Add and AddBatch only ever read and write the O(log n) nodes on
+// the current right spine (the same nodes MerkleTree.Append touches), and
+// because the tree is content-addressed and never mutates an existing
+// node, every root it has ever produced stays readable from storage after
+// later growth: each call is a copy-on-write step, not an in-place edit.
+//
+// The zero value is not usable; create one with NewIncrementalTree.
+type IncrementalTree struct {
+	hasher  Hasher
+	storage Storage
+
+	size int
+	root []byte
+
+	prevSize int
+	prevRoot []byte
+	hasPrev  bool
+}
+
+// NewIncrementalTree creates an empty IncrementalTree that stores its
+// nodes in storage, hashed with hasher. A nil hasher defaults to SHA256;
+// a nil storage defaults to a fresh MemStorage.
+func NewIncrementalTree(hasher Hasher, storage Storage) *IncrementalTree {
+	if hasher == nil {
+		hasher = SHA256
+	}
+	if storage == nil {
+		storage = NewMemStorage()
+	}
+	return &IncrementalTree{hasher: hasher, storage: storage}
+}
+
+// LeafCount returns the number of leaves added so far.
+func (t *IncrementalTree) LeafCount() int {
+	return t.size
+}
+
+// Snapshot returns the tree's current root hash.
+func (t *IncrementalTree) Snapshot() RootHash {
+	return RootHash(t.root)
+}
+
+// Add adds data as a new leaf, persisting only the nodes on the path from
+// the new leaf to the root.
+func (t *IncrementalTree) Add(data []byte) error {
+	return t.addLeafHash(t.hasher.HashLeaf(data))
+}
+
+func (t *IncrementalTree) addLeafHash(leafHash []byte) error {
+	prevSize, prevRoot := t.size, t.root
+
+	if t.size == 0 {
+		t.root = leafHash
+		t.size = 1
+	} else {
+		newRoot, err := addLeaf(t.hasher, t.storage, t.root, t.size, leafHash)
+		if err != nil {
+			return err
+		}
+		t.root = newRoot
+		t.size++
+	}
+
+	t.prevSize, t.prevRoot, t.hasPrev = prevSize, prevRoot, true
+	return nil
+}
+
+// addLeaf grows the subtree rooted at rootHash, which covers size leaves,
+// by attaching leaf immediately after it, persisting only the nodes this
+// creates, and returns the hash of the new root of the combined subtree.
+// It mirrors MerkleTree.Append's appendChild, but reads and writes nodes
+// through storage instead of following in-memory pointers.
+func addLeaf(hasher Hasher, storage Storage, rootHash []byte, size int, leafHash []byte) ([]byte, error) {
+	if size&(size-1) == 0 {
+		// rootHash is itself a complete power-of-two block, so it becomes
+		// the left child of a new parent with leaf on the right.
+		newRoot := hasher.HashChildren(rootHash, leafHash)
+		if err := storage.Put(newRoot, Node{Left: rootHash, Right: leafHash}); err != nil {
+			return nil, err
+		}
+		return newRoot, nil
+	}
+
+	node, err := storage.Get(rootHash)
+	if err != nil {
+		return nil, err
+	}
+
+	k := splitPoint(size)
+	newRight, err := addLeaf(hasher, storage, node.Right, size-k, leafHash)
+	if err != nil {
+		return nil, err
+	}
+
+	newRoot := hasher.HashChildren(node.Left, newRight)
+	if err := storage.Put(newRoot, Node{Left: node.Left, Right: newRight}); err != nil {
+		return nil, err
+	}
+	return newRoot, nil
+}
+
+// AddBatch adds data as new leaves, hashing them in parallel before
+// folding each one into the tree's right spine in a single pass, instead
+// of hashing one leaf at a time the way repeated Add calls would. For
+// batches smaller than minLeafsThreshold leaves, it falls back to Add,
+// where the extra bookkeeping would cost more than it saves.
+func (t *IncrementalTree) AddBatch(data [][]byte) error {
+	if len(data) < minLeafsThreshold {
+		for _, datum := range data {
+			if err := t.Add(datum); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	leafHashes := make([][]byte, len(data))
+	parallelFor(runtime.NumCPU(), len(data), func(i int) {
+		leafHashes[i] = t.hasher.HashLeaf(data[i])
+	})
+
+	prevSize, prevRoot := t.size, t.root
+	for _, leafHash := range leafHashes {
+		if t.size == 0 {
+			t.root = leafHash
+			t.size = 1
+			continue
+		}
+		newRoot, err := addLeaf(t.hasher, t.storage, t.root, t.size, leafHash)
+		if err != nil {
+			return err
+		}
+		t.root = newRoot
+		t.size++
+	}
+
+	t.prevSize, t.prevRoot, t.hasPrev = prevSize, prevRoot, true
+	return nil
+}
+
+// Delete undoes the most recent Add or AddBatch call, restoring the size
+// and root the tree had before it. IncrementalTree is an append-only,
+// content-addressed accumulator: removing an arbitrary earlier leaf would
+// require rebuilding every node added after it and would invalidate every
+// proof taken against the roots in between, so Delete only ever rolls
+// back the single most recent change, and can't be called twice in a row.
+func (t *IncrementalTree) Delete() error {
+	if !t.hasPrev {
+		return errors.New("binary: no previous Add or AddBatch to undo")
+	}
+	t.size, t.root = t.prevSize, t.prevRoot
+	t.hasPrev = false
+	return nil
+}
+
+// parallelFor calls fn(i) for every i in [0, n), spread across up to
+// workers goroutines, and waits for all of them to finish.
+func parallelFor(workers, n int, fn func(i int)) {
+	if n == 0 {
+		return
+	}
+	if workers < 1 || workers > n {
+		workers = n
+	}
+	if workers <= 1 {
+		for i := 0; i < n; i++ {
+			fn(i)
+		}
+		return
+	}
+
+	chunk := (n + workers - 1) / workers
+	var wg sync.WaitGroup
+	for lo := 0; lo < n; lo += chunk {
+		hi := lo + chunk
+		if hi > n {
+			hi = n
+		}
+
+		wg.Add(1)
+		go func(lo, hi int) {
+			defer wg.Done()
+			for i := lo; i < hi; i++ {
+				fn(i)
+			}
+		}(lo, hi)
+	}
+	wg.Wait()
+}
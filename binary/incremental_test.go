@@ -0,0 +1,153 @@
+/*
+ * Copyright © 2018 Lynn <lynn9388@gmail.com>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package binary
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+func TestIncrementalTree_Add(t *testing.T) {
+	for n := 0; n < 20; n++ {
+		data := make([][]byte, n)
+		for i := range data {
+			data[i] = []byte{byte(i)}
+		}
+
+		it := NewIncrementalTree(nil, nil)
+		for i, datum := range data {
+			if err := it.Add(datum); err != nil {
+				t.Fatalf("n=%d: Add(%d) error = %v", n, i, err)
+			}
+
+			want := New(data[:i+1]...)
+			if !bytes.Equal(it.Snapshot(), want.Hash) {
+				t.Fatalf("n=%d: after Add(%d), Snapshot() = %x, want %x", n, i, it.Snapshot(), want.Hash)
+			}
+			if it.LeafCount() != i+1 {
+				t.Fatalf("n=%d: after Add(%d), LeafCount() = %d, want %d", n, i, it.LeafCount(), i+1)
+			}
+		}
+	}
+}
+
+func TestIncrementalTree_AddBatch(t *testing.T) {
+	data := make([][]byte, 200)
+	for i := range data {
+		data[i] = []byte{byte(i), byte(i >> 8)}
+	}
+	want := New(data...)
+
+	it := NewIncrementalTree(nil, nil)
+	if err := it.AddBatch(data); err != nil {
+		t.Fatalf("AddBatch() error = %v", err)
+	}
+	if !bytes.Equal(it.Snapshot(), want.Hash) {
+		t.Errorf("AddBatch().Snapshot() = %x, want %x", it.Snapshot(), want.Hash)
+	}
+	if it.LeafCount() != len(data) {
+		t.Errorf("LeafCount() = %d, want %d", it.LeafCount(), len(data))
+	}
+}
+
+func TestIncrementalTree_AddBatchBelowThreshold(t *testing.T) {
+	data := make([][]byte, minLeafsThreshold-1)
+	for i := range data {
+		data[i] = []byte{byte(i)}
+	}
+	want := New(data...)
+
+	it := NewIncrementalTree(nil, nil)
+	if err := it.AddBatch(data); err != nil {
+		t.Fatalf("AddBatch() error = %v", err)
+	}
+	if !bytes.Equal(it.Snapshot(), want.Hash) {
+		t.Errorf("AddBatch().Snapshot() = %x, want %x", it.Snapshot(), want.Hash)
+	}
+}
+
+func TestIncrementalTree_PreviousRootsStayReadable(t *testing.T) {
+	storage := NewMemStorage()
+	it := NewIncrementalTree(SHA256, storage)
+
+	var roots []RootHash
+	for i := 0; i < 10; i++ {
+		if err := it.Add([]byte{byte(i)}); err != nil {
+			t.Fatalf("Add(%d) error = %v", i, err)
+		}
+		roots = append(roots, it.Snapshot())
+	}
+
+	for n, root := range roots {
+		got, err := addLeaf(SHA256, storage, root, n+1, SHA256.HashLeaf([]byte{byte(n + 1)}))
+		if err != nil {
+			t.Fatalf("addLeaf() from an earlier root (n=%d) error = %v", n+1, err)
+		}
+		if n+1 < len(roots) {
+			if !bytes.Equal(got, roots[n+1]) {
+				t.Errorf("addLeaf() from root at size %d = %x, want %x", n+1, got, roots[n+1])
+			}
+		}
+	}
+}
+
+func TestIncrementalTree_Delete(t *testing.T) {
+	it := NewIncrementalTree(nil, nil)
+	if err := it.Delete(); err == nil {
+		t.Error("Delete() on a fresh tree expected error, got nil")
+	}
+
+	if err := it.Add([]byte("a")); err != nil {
+		t.Fatalf("Add() error = %v", err)
+	}
+	if err := it.Add([]byte("b")); err != nil {
+		t.Fatalf("Add() error = %v", err)
+	}
+	want := New([]byte("a"))
+
+	if err := it.Delete(); err != nil {
+		t.Fatalf("Delete() error = %v", err)
+	}
+	if !bytes.Equal(it.Snapshot(), want.Hash) {
+		t.Errorf("Snapshot() after Delete() = %x, want %x", it.Snapshot(), want.Hash)
+	}
+	if it.LeafCount() != 1 {
+		t.Errorf("LeafCount() after Delete() = %d, want 1", it.LeafCount())
+	}
+
+	if err := it.Delete(); err == nil {
+		t.Error("Delete() called twice in a row expected error, got nil")
+	}
+}
+
+type errStorage struct{}
+
+func (errStorage) Get(hash []byte) (Node, error) { return Node{}, ErrNodeNotFound }
+func (errStorage) Put(hash []byte, node Node) error {
+	return errors.New("binary: simulated storage failure")
+}
+
+func TestIncrementalTree_StoragePutError(t *testing.T) {
+	it := NewIncrementalTree(nil, errStorage{})
+	if err := it.Add([]byte("a")); err != nil {
+		t.Fatalf("Add() error = %v", err)
+	}
+	if err := it.Add([]byte("b")); err == nil {
+		t.Error("Add() with a failing Storage expected error, got nil")
+	}
+}
@@ -0,0 +1,41 @@
+/*
+ * Copyright © 2018 Lynn <lynn9388@gmail.com>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package binary
+
+// TreeLayout returns, for a range of n > 1 leaves, how many of them belong
+// to the left subtree (the rest go to the right). New, ConsistencyProof,
+// and GetMultiProof's verifiers all split ranges this way, so a proof can
+// only be checked against a tree built with the same TreeLayout it used.
+type TreeLayout func(n int) int
+
+// LayoutRFC6962 puts the largest power-of-two number of leaves strictly
+// less than n on the left and the remainder on the right, as specified by
+// RFC 6962's MTH. This is the layout New has always used, and the only one
+// MerkleTree.Append supports incremental growth for.
+var LayoutRFC6962 TreeLayout = splitPoint
+
+// LayoutBalanced splits a range into two halves that differ by at most one
+// leaf (Tendermint's "simple tree" style: left = (n+1)/2, right = n/2),
+// instead of aligning the left side to a power of two.
+//
+// Unlike LayoutRFC6962, LayoutBalanced does not align every snapshot size
+// to a node boundary in every larger tree, so ConsistencyProof and
+// VerifyConsistencyProofWithOptions cannot be relied on to produce a valid
+// proof for an arbitrary (m, n) pair under this layout; GetAuditPath,
+// GetMultiProof, and their verifiers are unaffected, since they only ever
+// need the single size n they were built or are being checked against.
+var LayoutBalanced TreeLayout = func(n int) int { return (n + 1) / 2 }
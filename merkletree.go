@@ -20,9 +20,9 @@ package merkletree
 
 import (
 	"bytes"
-	"crypto/sha256"
 	"encoding/hex"
 	"errors"
+	"math"
 	"strings"
 )
 
@@ -44,6 +44,16 @@ type MerkleNode struct {
 // root of the tree.
 type MerkleTree struct {
 	Root *MerkleNode
+
+	// hasher is the Hasher the tree was built with; it is also used to
+	// locate leaves and build proofs against this tree. The Verify
+	// functions do not read it; they take their own Hasher.
+	hasher Hasher
+
+	// leaves holds the leaf hashes in their original order, used by
+	// ConsistencyProof to recompute the hash of any prefix of the leaf
+	// sequence.
+	leaves [][]byte
 }
 
 // Proof is a item in data's proof path.
@@ -53,16 +63,16 @@ type Proof struct {
 }
 
 // newMerkleNode creates a new node.
-func newMerkleNode(left *MerkleNode, right *MerkleNode, data []byte) *MerkleNode {
-	var hash [32]byte
+func newMerkleNode(hasher Hasher, left *MerkleNode, right *MerkleNode, data []byte) *MerkleNode {
+	var hash []byte
 
 	if left == nil && right == nil {
-		hash = sha256.Sum256(data)
+		hash = hasher.HashLeaf(data)
 	} else {
-		hash = sha256.Sum256(append(left.Hash, right.Hash...))
+		hash = hasher.HashChildren(left.Hash, right.Hash)
 	}
 
-	node := MerkleNode{Left: left, Right: right, Hash: hash[:]}
+	node := MerkleNode{Left: left, Right: right, Hash: hash}
 	if left != nil {
 		left.Parent = &node
 	}
@@ -73,20 +83,48 @@ func newMerkleNode(left *MerkleNode, right *MerkleNode, data []byte) *MerkleNode
 	return &node
 }
 
-// NewMerkleTree builds a new Merkle tree using the data.
+// NewMerkleTree builds a new Merkle tree using the data, hashed with
+// SHA256. Use NewMerkleTreeWithOptions to build one with a different
+// Hasher. With no data, it returns a tree with a nil Root.
 func NewMerkleTree(data ...[]byte) *MerkleTree {
-	var nodes []*MerkleNode
+	return NewMerkleTreeWithOptions(nil, data...)
+}
 
+// NewMerkleTreeWithOptions builds a new Merkle tree using the data, as
+// configured by opts (see WithHasher). It defaults to SHA256 when no
+// options are given.
+func NewMerkleTreeWithOptions(opts []Option, data ...[]byte) *MerkleTree {
+	o := &options{hasher: SHA256}
+	for _, opt := range opts {
+		opt(o)
+	}
+
+	var nodes []*MerkleNode
 	for _, datum := range data {
-		nodes = append(nodes, newMerkleNode(nil, nil, datum))
+		nodes = append(nodes, newMerkleNode(o.hasher, nil, nil, datum))
+	}
+
+	leaves := make([][]byte, len(nodes))
+	for i, node := range nodes {
+		leaves[i] = node.Hash
+	}
+
+	return &MerkleTree{Root: reduceToRoot(o.hasher, nodes), hasher: o.hasher, leaves: leaves}
+}
+
+// reduceToRoot repeatedly combines adjacent pairs of nodes into parents,
+// carrying an odd node up a level unpaired, until a single root remains.
+// It returns nil for an empty slice of nodes.
+func reduceToRoot(hasher Hasher, nodes []*MerkleNode) *MerkleNode {
+	if len(nodes) == 0 {
+		return nil
 	}
 
 	for len(nodes) != 1 {
 		var parents []*MerkleNode
 
 		for i := 0; i+1 < len(nodes); i += 2 {
-			node := newMerkleNode(nodes[i], nodes[i+1], append(nodes[i].Hash, nodes[i+1].Hash...))
-			parents = append(parents, node)
+			parents = append(parents, newMerkleNode(hasher, nodes[i], nodes[i+1], nil))
 		}
 
 		if len(nodes)%2 != 0 {
@@ -95,17 +133,16 @@ func NewMerkleTree(data ...[]byte) *MerkleTree {
 
 		nodes = parents
 	}
-
-	return &MerkleTree{Root: nodes[0]}
+	return nodes[0]
 }
 
 // findNode finds the leaf node with the same hash value.
-func (mn *MerkleNode) findNode(hash [32]byte) *MerkleNode {
+func (mn *MerkleNode) findNode(hash []byte) *MerkleNode {
 	if mn == nil {
 		return nil
 	}
 
-	if mn.Left == nil && mn.Right == nil && bytes.Equal(mn.Hash, hash[:]) {
+	if mn.Left == nil && mn.Right == nil && bytes.Equal(mn.Hash, hash) {
 		return mn
 	}
 
@@ -121,7 +158,7 @@ func (mn *MerkleNode) findNode(hash [32]byte) *MerkleNode {
 func (mt *MerkleTree) GetProof(data []byte) ([]Proof, error) {
 	var ps []Proof
 
-	node := mt.Root.findNode(sha256.Sum256(data))
+	node := mt.Root.findNode(mt.hasher.HashLeaf(data))
 	if node == nil {
 		return nil, errors.New("failed to find leaf node")
 	}
@@ -140,20 +177,155 @@ func (mt *MerkleTree) GetProof(data []byte) ([]Proof, error) {
 }
 
 // VerifyProof verifies if a proof is valid (the data's hash is a leaf of
-// the Merkle tree).
+// the Merkle tree), using SHA256. Use VerifyProofWithHasher to verify a
+// proof produced by a tree built with a different Hasher.
 func VerifyProof(data []byte, ps []Proof, root []byte) bool {
-	var hash [32]byte
-	hash = sha256.Sum256(data)
+	return VerifyProofWithHasher(SHA256, data, ps, root)
+}
+
+// VerifyProofWithHasher verifies if a proof is valid (the data's hash is a
+// leaf of the Merkle tree), using hasher to recompute intermediate hashes.
+func VerifyProofWithHasher(hasher Hasher, data []byte, ps []Proof, root []byte) bool {
+	hash := hasher.HashLeaf(data)
 
 	for _, p := range ps {
 		if p.Order == left {
-			hash = sha256.Sum256(append(p.Hash, hash[:]...))
+			hash = hasher.HashChildren(p.Hash, hash)
 		} else if p.Order == right {
-			hash = sha256.Sum256(append(hash[:], p.Hash...))
+			hash = hasher.HashChildren(hash, p.Hash)
 		}
 	}
 
-	return bytes.Equal(hash[:], root)
+	return bytes.Equal(hash, root)
+}
+
+// LeafCount returns the number of leaves the tree was built from.
+func (mt *MerkleTree) LeafCount() int {
+	return len(mt.leaves)
+}
+
+// LeafHashAt returns the hash of the i-th leaf in the tree, in the order
+// the tree was built.
+func (mt *MerkleTree) LeafHashAt(i int) ([]byte, error) {
+	if i < 0 || i >= len(mt.leaves) {
+		return nil, errors.New("leaf index out of range")
+	}
+	return mt.leaves[i], nil
+}
+
+// splitPoint returns the largest power of two strictly less than n.
+func splitPoint(n int) int {
+	return int(math.Exp2(math.Ceil(math.Log2(float64(n)) - 1)))
+}
+
+// mth (Merkle Tree Hash) recomputes the hash of a sequence of leaf hashes
+// by splitting it at splitPoint, the same layout used by ConsistencyProof.
+// NewMerkleTree combines leaves level by level and carries an odd node up
+// unpaired, which builds the same shape as splitting at the largest power
+// of two, so mth of the full leaf sequence equals mt.Root.Hash.
+func mth(hasher Hasher, leafHashes [][]byte) []byte {
+	n := len(leafHashes)
+	if n == 0 {
+		return hasher.HashLeaf([]byte{})
+	}
+	if n == 1 {
+		return leafHashes[0]
+	}
+	k := splitPoint(n)
+	return hasher.HashChildren(mth(hasher, leafHashes[:k]), mth(hasher, leafHashes[k:]))
+}
+
+// subProof implements the RFC 6962 SUBPROOF algorithm: it returns the list
+// of hashes a verifier needs, in order, to recompute both mth(d[:m]) and
+// mth(d). b is true while the two trees have not yet diverged from a
+// common left-aligned prefix.
+func subProof(hasher Hasher, m int, d [][]byte, b bool) [][]byte {
+	n := len(d)
+	if m == n {
+		if b {
+			return [][]byte{}
+		}
+		return [][]byte{mth(hasher, d)}
+	}
+
+	k := splitPoint(n)
+	if m <= k {
+		return append(subProof(hasher, m, d[:k], b), mth(hasher, d[k:]))
+	}
+	return append(subProof(hasher, m-k, d[k:], false), mth(hasher, d[:k]))
+}
+
+// ConsistencyProof returns the list of hashes proving that the root of the
+// first m leaves is consistent with the root of the first n leaves, i.e.
+// that the tree of size m is a prefix of the tree of size n. m and n must
+// both be within [0, mt.LeafCount()].
+func (mt *MerkleTree) ConsistencyProof(m, n int) ([][]byte, error) {
+	if m < 0 || n < m || n > len(mt.leaves) {
+		return nil, errors.New("invalid tree sizes")
+	}
+	if m == 0 || m == n {
+		return [][]byte{}, nil
+	}
+	return subProof(mt.hasher, m, mt.leaves[:n], true), nil
+}
+
+// foldConsistency mirrors subProof's recursion to fold a consistency proof
+// back into the old and new root hashes it commits to.
+func foldConsistency(hasher Hasher, m, n int, b bool, oldRoot []byte, proof [][]byte) (oldHash, newHash []byte, rest [][]byte, ok bool) {
+	if m == n {
+		if b {
+			return oldRoot, oldRoot, proof, true
+		}
+		if len(proof) == 0 {
+			return nil, nil, nil, false
+		}
+		return proof[0], proof[0], proof[1:], true
+	}
+
+	k := splitPoint(n)
+	if m <= k {
+		old, new, rest, ok := foldConsistency(hasher, m, k, b, oldRoot, proof)
+		if !ok || len(rest) == 0 {
+			return nil, nil, nil, false
+		}
+		return old, hasher.HashChildren(new, rest[0]), rest[1:], true
+	}
+
+	old, new, rest, ok := foldConsistency(hasher, m-k, n-k, false, oldRoot, proof)
+	if !ok || len(rest) == 0 {
+		return nil, nil, nil, false
+	}
+	left := rest[0]
+	return hasher.HashChildren(left, old), hasher.HashChildren(left, new), rest[1:], true
+}
+
+// VerifyConsistencyProof verifies that oldRoot (the root hash of the first
+// m leaves) and newRoot (the root hash of the first n leaves) are
+// consistent according to proof, as returned by ConsistencyProof, using
+// SHA256. Use VerifyConsistencyProofWithHasher for a tree built with a
+// different Hasher.
+func VerifyConsistencyProof(m, n int, oldRoot, newRoot []byte, proof [][]byte) bool {
+	return VerifyConsistencyProofWithHasher(SHA256, m, n, oldRoot, newRoot, proof)
+}
+
+// VerifyConsistencyProofWithHasher verifies a consistency proof produced by
+// a tree built with hasher. See VerifyConsistencyProof.
+func VerifyConsistencyProofWithHasher(hasher Hasher, m, n int, oldRoot, newRoot []byte, proof [][]byte) bool {
+	if m < 0 || n < m {
+		return false
+	}
+	if m == 0 {
+		return true
+	}
+	if m == n {
+		return len(proof) == 0 && bytes.Equal(oldRoot, newRoot)
+	}
+
+	old, new, rest, ok := foldConsistency(hasher, m, n, true, oldRoot, proof)
+	if !ok || len(rest) != 0 {
+		return false
+	}
+	return bytes.Equal(old, oldRoot) && bytes.Equal(new, newRoot)
 }
 
 // PrettyString returns a format string to present the Merkle tree. width
@@ -17,8 +17,11 @@
 package merkletree
 
 import (
+	"bytes"
+	"crypto/sha256"
 	"encoding/hex"
 	"fmt"
+	"reflect"
 	"strings"
 	"testing"
 )
@@ -85,21 +88,145 @@ func TestIsProofValid(t *testing.T) {
 	tests := [][]byte{[]byte("http"), []byte("www"), []byte("lynn"), []byte("9388"), []byte("com")}
 	mt := NewMerkleTree(tests[2])
 	proof, err := mt.GetProof(tests[0])
-	if err == nil || proof != nil || IsProofValid(tests[0], proof, mt.Root.Hash) == true {
+	if err == nil || proof != nil || VerifyProof(tests[0], proof, mt.Root.Hash) == true {
 		t.Error("failed in case 0")
 	}
 	proof, err = mt.GetProof(tests[2])
-	if err != nil || proof != nil || IsProofValid(tests[2], proof, mt.Root.Hash) == false {
+	if err != nil || proof != nil || VerifyProof(tests[2], proof, mt.Root.Hash) == false {
 		t.Error("failed in case 1")
 	}
 
 	mt = NewMerkleTree(tests...)
 	proof, err = mt.GetProof(tests[0])
-	if err != nil || proof == nil || IsProofValid(tests[0], proof, mt.Root.Hash) == false {
+	if err != nil || proof == nil || VerifyProof(tests[0], proof, mt.Root.Hash) == false {
 		t.Error("failed in case 2")
 	}
 	proof, err = mt.GetProof(tests[2])
-	if err != nil || proof == nil || IsProofValid(tests[2], proof, mt.Root.Hash) == false {
+	if err != nil || proof == nil || VerifyProof(tests[2], proof, mt.Root.Hash) == false {
 		t.Error("failed in case 3")
 	}
 }
+
+func TestMerkleTree_ConsistencyProof(t *testing.T) {
+	tests := [][]byte{[]byte("http"), []byte("www"), []byte("lynn"), []byte("9388"), []byte("com")}
+	mt := NewMerkleTree(tests...)
+
+	for m := 0; m <= len(tests); m++ {
+		oldRoot := mth(mt.hasher, mt.leaves[:m])
+		proof, err := mt.ConsistencyProof(m, len(tests))
+		if err != nil {
+			t.Fatalf("ConsistencyProof(%d, %d) error = %v", m, len(tests), err)
+		}
+		if !VerifyConsistencyProof(m, len(tests), oldRoot, mth(mt.hasher, mt.leaves), proof) {
+			t.Errorf("VerifyConsistencyProof(%d, %d) = false, want true", m, len(tests))
+		}
+	}
+
+	if _, err := mt.ConsistencyProof(-1, len(tests)); err == nil {
+		t.Error("ConsistencyProof(-1, n) expected error")
+	}
+	if _, err := mt.ConsistencyProof(2, len(tests)+1); err == nil {
+		t.Error("ConsistencyProof(m, n) with n > LeafCount() expected error")
+	}
+	if VerifyConsistencyProof(3, 2, nil, nil, nil) {
+		t.Error("VerifyConsistencyProof(m, n) with m > n expected false")
+	}
+}
+
+func TestNewMerkleTree_NoData(t *testing.T) {
+	if mt := NewMerkleTree(); mt.Root != nil {
+		t.Errorf("NewMerkleTree() with no data Root = %v, want nil", mt.Root)
+	}
+}
+
+func TestNewMerkleTreeWithOptions(t *testing.T) {
+	tests := [][]byte{[]byte("http"), []byte("www"), []byte("lynn")}
+
+	for _, hasher := range []Hasher{SHA256, SHA512_256, BLAKE2b, Keccak256} {
+		mt := NewMerkleTreeWithOptions([]Option{WithHasher(hasher)}, tests...)
+		proof, err := mt.GetProof(tests[1])
+		if err != nil {
+			t.Fatalf("GetProof() error = %v", err)
+		}
+		if !VerifyProofWithHasher(hasher, tests[1], proof, mt.Root.Hash) {
+			t.Errorf("VerifyProofWithHasher() = false, want true")
+		}
+		if VerifyProof(tests[1], proof, mt.Root.Hash) && hasher != SHA256 {
+			t.Errorf("VerifyProof() = true for a tree built with a different Hasher, want false")
+		}
+	}
+}
+
+func TestMerkleTree_MultiProof(t *testing.T) {
+	tests := [][]byte{[]byte("http"), []byte("www"), []byte("lynn"), []byte("9388"), []byte("com")}
+	mt := NewMerkleTree(tests...)
+
+	datas := [][]byte{tests[0], tests[3]}
+	mp, err := mt.GetMultiProof(datas...)
+	if err != nil {
+		t.Fatalf("GetMultiProof() error = %v", err)
+	}
+	if !reflect.DeepEqual(mp.Indices, []int{0, 3}) {
+		t.Errorf("GetMultiProof().Indices = %v, want [0 3]", mp.Indices)
+	}
+	if !VerifyMultiProof(datas, mp, mt.Root.Hash) {
+		t.Error("VerifyMultiProof() = false, want true")
+	}
+	if VerifyMultiProof([][]byte{tests[1], tests[3]}, mp, mt.Root.Hash) {
+		t.Error("VerifyMultiProof() = true for mismatched data, want false")
+	}
+
+	roundTripped, err := DeserializeBinary(mp.SerializeBinary())
+	if err != nil {
+		t.Fatalf("DeserializeBinary() error = %v", err)
+	}
+	if !reflect.DeepEqual(mp, roundTripped) {
+		t.Errorf("DeserializeBinary(SerializeBinary()) = %v, want %v", roundTripped, mp)
+	}
+
+	if _, err := mt.GetMultiProof(); err == nil {
+		t.Error("GetMultiProof() with no data expected error")
+	}
+	if _, err := mt.GetMultiProof([]byte("missing")); err == nil {
+		t.Error("GetMultiProof() with unknown data expected error")
+	}
+}
+
+func TestMerkleTree_MultiProofAllLeaves(t *testing.T) {
+	tests := make([][]byte, 13)
+	for i := range tests {
+		tests[i] = []byte{byte(i)}
+	}
+	mt := NewMerkleTree(tests...)
+
+	for m := 1; m <= len(tests); m++ {
+		datas := tests[:m]
+		mp, err := mt.GetMultiProof(datas...)
+		if err != nil {
+			t.Fatalf("GetMultiProof(%d leaves) error = %v", m, err)
+		}
+		if !VerifyMultiProof(datas, mp, mt.Root.Hash) {
+			t.Errorf("VerifyMultiProof(%d leaves) = false, want true", m)
+		}
+	}
+}
+
+func TestMerkleTree_LeafHashAt(t *testing.T) {
+	tests := [][]byte{[]byte("http"), []byte("www"), []byte("lynn")}
+	mt := NewMerkleTree(tests...)
+
+	got, err := mt.LeafHashAt(1)
+	if err != nil {
+		t.Fatalf("LeafHashAt(1) error = %v", err)
+	}
+	want := sha256.Sum256(tests[1])
+	if !bytes.Equal(got, want[:]) {
+		t.Errorf("LeafHashAt(1) = %x, want %x", got, want)
+	}
+	if _, err := mt.LeafHashAt(3); err == nil {
+		t.Error("LeafHashAt(3) expected error for out of range index")
+	}
+	if mt.LeafCount() != 3 {
+		t.Errorf("LeafCount() = %v, want 3", mt.LeafCount())
+	}
+}
@@ -0,0 +1,310 @@
+/*
+ * Copyright © 2018 Lynn <lynn9388@gmail.com>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package merkletree
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"sort"
+)
+
+// MultiProof authenticates several leaves of a MerkleTree with a single
+// compact proof, using the same encoding as the PartialMerkleTree Bitcoin
+// SPV clients exchange in merkleblock messages: a depth-first flag stream
+// (recurse into a node, or take its hash from Hashes) plus the list of
+// pruned hashes, so shared ancestors of the proven leaves are only
+// authenticated once.
+type MultiProof struct {
+	// N is the total number of leaves in the tree the proof was taken
+	// from, needed to rebuild the same tree shape during verification.
+	N int
+
+	// Indices holds the sorted leaf indices the proof authenticates.
+	Indices []int
+
+	// Flags is the depth-first flag stream: true means "this node is on
+	// the path to a proven leaf, recurse into it (or, at a leaf, it is
+	// one of the proven leaves)"; false means "take the next hash from
+	// Hashes instead of recursing".
+	Flags []bool
+
+	// Hashes holds, in depth-first order, the hash of every node whose
+	// flag is false.
+	Hashes [][]byte
+}
+
+// leafCount returns the number of leaves in the subtree rooted at mn.
+func (mn *MerkleNode) leafCount() int {
+	if mn.Left == nil && mn.Right == nil {
+		return 1
+	}
+	return mn.Left.leafCount() + mn.Right.leafCount()
+}
+
+// GetMultiProof returns a single proof authenticating all of datas at once.
+func (mt *MerkleTree) GetMultiProof(datas ...[]byte) (*MultiProof, error) {
+	if len(datas) == 0 {
+		return nil, errors.New("no data given")
+	}
+
+	indexOf := make(map[string]int, len(mt.leaves))
+	for i, leaf := range mt.leaves {
+		indexOf[string(leaf)] = i
+	}
+
+	targets := make(map[int]bool, len(datas))
+	for _, data := range datas {
+		i, ok := indexOf[string(mt.hasher.HashLeaf(data))]
+		if !ok {
+			return nil, errors.New("data not found in tree")
+		}
+		targets[i] = true
+	}
+
+	mp := &MultiProof{N: len(mt.leaves)}
+	for i := range targets {
+		mp.Indices = append(mp.Indices, i)
+	}
+	sort.Ints(mp.Indices)
+
+	var build func(node *MerkleNode, lo int)
+	build = func(node *MerkleNode, lo int) {
+		n := node.leafCount()
+		matched := false
+		for i := lo; i < lo+n; i++ {
+			if targets[i] {
+				matched = true
+				break
+			}
+		}
+
+		mp.Flags = append(mp.Flags, matched)
+		if !matched {
+			mp.Hashes = append(mp.Hashes, node.Hash)
+			return
+		}
+		if node.Left == nil && node.Right == nil {
+			return
+		}
+
+		build(node.Left, lo)
+		build(node.Right, lo+node.Left.leafCount())
+	}
+	build(mt.Root, 0)
+
+	return mp, nil
+}
+
+// multiProofShape mirrors the node that NewMerkleTreeWithOptions would have
+// built at [lo, hi), without needing the leaf data, so VerifyMultiProof can
+// replay GetMultiProof's depth-first traversal.
+type multiProofShape struct {
+	left, right *multiProofShape
+	lo, hi      int
+}
+
+// buildMultiProofShape rebuilds the shape of a tree of n leaves, following
+// the same level-by-level pairing (and odd-node carry) NewMerkleTreeWithOptions
+// uses, so its node boundaries line up exactly with the real tree's.
+func buildMultiProofShape(n int) *multiProofShape {
+	nodes := make([]*multiProofShape, n)
+	for i := range nodes {
+		nodes[i] = &multiProofShape{lo: i, hi: i + 1}
+	}
+
+	for len(nodes) != 1 {
+		var parents []*multiProofShape
+		for i := 0; i+1 < len(nodes); i += 2 {
+			parents = append(parents, &multiProofShape{left: nodes[i], right: nodes[i+1], lo: nodes[i].lo, hi: nodes[i+1].hi})
+		}
+		if len(nodes)%2 != 0 {
+			parents = append(parents, nodes[len(nodes)-1])
+		}
+		nodes = parents
+	}
+	return nodes[0]
+}
+
+// VerifyMultiProof verifies a MultiProof against datas, using SHA256. datas
+// must be given in ascending order of mp.Indices. Use
+// VerifyMultiProofWithHasher to verify a proof from a tree built with a
+// different Hasher.
+func VerifyMultiProof(datas [][]byte, mp *MultiProof, root []byte) bool {
+	return VerifyMultiProofWithHasher(SHA256, datas, mp, root)
+}
+
+// VerifyMultiProofWithHasher verifies a MultiProof produced by a tree built
+// with hasher. See VerifyMultiProof.
+func VerifyMultiProofWithHasher(hasher Hasher, datas [][]byte, mp *MultiProof, root []byte) bool {
+	if mp == nil || mp.N <= 0 || len(datas) != len(mp.Indices) {
+		return false
+	}
+
+	var fi, hi, di int
+	var walk func(s *multiProofShape) ([]byte, bool)
+	walk = func(s *multiProofShape) ([]byte, bool) {
+		if fi >= len(mp.Flags) {
+			return nil, false
+		}
+		matched := mp.Flags[fi]
+		fi++
+
+		if !matched {
+			if hi >= len(mp.Hashes) {
+				return nil, false
+			}
+			hash := mp.Hashes[hi]
+			hi++
+			return hash, true
+		}
+
+		if s.left == nil && s.right == nil {
+			if di >= len(datas) {
+				return nil, false
+			}
+			hash := hasher.HashLeaf(datas[di])
+			di++
+			return hash, true
+		}
+
+		left, ok := walk(s.left)
+		if !ok {
+			return nil, false
+		}
+		right, ok := walk(s.right)
+		if !ok {
+			return nil, false
+		}
+		return hasher.HashChildren(left, right), true
+	}
+
+	got, ok := walk(buildMultiProofShape(mp.N))
+	if !ok || fi != len(mp.Flags) || hi != len(mp.Hashes) || di != len(datas) {
+		return false
+	}
+	return bytes.Equal(got, root)
+}
+
+// SerializeBinary encodes the proof for on-the-wire use.
+func (mp *MultiProof) SerializeBinary() []byte {
+	var buf bytes.Buffer
+
+	writeUint32(&buf, uint32(mp.N))
+
+	writeUint32(&buf, uint32(len(mp.Indices)))
+	for _, i := range mp.Indices {
+		writeUint32(&buf, uint32(i))
+	}
+
+	writeUint32(&buf, uint32(len(mp.Flags)))
+	buf.Write(packFlags(mp.Flags))
+
+	writeUint32(&buf, uint32(len(mp.Hashes)))
+	for _, hash := range mp.Hashes {
+		writeUint32(&buf, uint32(len(hash)))
+		buf.Write(hash)
+	}
+
+	return buf.Bytes()
+}
+
+// DeserializeBinary decodes a proof encoded by MultiProof.SerializeBinary.
+func DeserializeBinary(data []byte) (*MultiProof, error) {
+	r := bytes.NewReader(data)
+
+	n, err := readUint32(r)
+	if err != nil {
+		return nil, err
+	}
+	mp := &MultiProof{N: int(n)}
+
+	numIndices, err := readUint32(r)
+	if err != nil {
+		return nil, err
+	}
+	mp.Indices = make([]int, numIndices)
+	for i := range mp.Indices {
+		v, err := readUint32(r)
+		if err != nil {
+			return nil, err
+		}
+		mp.Indices[i] = int(v)
+	}
+
+	numFlags, err := readUint32(r)
+	if err != nil {
+		return nil, err
+	}
+	packed := make([]byte, (numFlags+7)/8)
+	if _, err := io.ReadFull(r, packed); err != nil {
+		return nil, err
+	}
+	mp.Flags = unpackFlags(packed, int(numFlags))
+
+	numHashes, err := readUint32(r)
+	if err != nil {
+		return nil, err
+	}
+	mp.Hashes = make([][]byte, numHashes)
+	for i := range mp.Hashes {
+		hashLen, err := readUint32(r)
+		if err != nil {
+			return nil, err
+		}
+		hash := make([]byte, hashLen)
+		if _, err := io.ReadFull(r, hash); err != nil {
+			return nil, err
+		}
+		mp.Hashes[i] = hash
+	}
+
+	return mp, nil
+}
+
+func writeUint32(buf *bytes.Buffer, v uint32) {
+	var b [4]byte
+	binary.BigEndian.PutUint32(b[:], v)
+	buf.Write(b[:])
+}
+
+func readUint32(r *bytes.Reader) (uint32, error) {
+	var b [4]byte
+	if _, err := io.ReadFull(r, b[:]); err != nil {
+		return 0, err
+	}
+	return binary.BigEndian.Uint32(b[:]), nil
+}
+
+func packFlags(flags []bool) []byte {
+	packed := make([]byte, (len(flags)+7)/8)
+	for i, f := range flags {
+		if f {
+			packed[i/8] |= 1 << uint(i%8)
+		}
+	}
+	return packed
+}
+
+func unpackFlags(packed []byte, n int) []bool {
+	flags := make([]bool, n)
+	for i := range flags {
+		flags[i] = packed[i/8]&(1<<uint(i%8)) != 0
+	}
+	return flags
+}
@@ -0,0 +1,390 @@
+/*
+ * Copyright © 2018 Lynn <lynn9388@gmail.com>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package namespaced implements a Namespaced Merkle Tree (NMT), as used by
+// Celestia-style data availability layers: every leaf carries a fixed-size
+// namespace ID prepended to its data, and every internal node additionally
+// stores the minimum and maximum namespace ID covered by its subtree. This
+// lets a light client both prove that a leaf with a given namespace exists
+// (and recover the contiguous range of leaves sharing it) and prove that no
+// leaf with a given namespace exists at all, without downloading the whole
+// tree.
+package namespaced
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"errors"
+	"math/bits"
+	"sort"
+)
+
+// ErrInvalidPushOrder is returned by Push when nsID is smaller than the
+// namespace ID of the leaf most recently pushed. Leaves must be pushed in
+// non-decreasing namespace order so that every namespace occupies a single
+// contiguous range of leaves.
+var ErrInvalidPushOrder = errors.New("namespaced: leaves must be pushed in non-decreasing namespace order")
+
+// Node is a node of a Tree: the range of namespace IDs covered by its
+// subtree, and its hash. A leaf Node has MinNS == MaxNS == its namespace
+// ID.
+type Node struct {
+	MinNS []byte
+	MaxNS []byte
+	Hash  []byte
+}
+
+// Hasher computes the leaf and internal node hashes of a Tree. HashChildren
+// is given the full child Nodes, not just their hashes, so it can fold the
+// namespace range into the hash (binding a node's range to its content).
+type Hasher interface {
+	Size() int
+	HashLeaf(nsID, data []byte) []byte
+	HashChildren(left, right Node) []byte
+}
+
+type sha256Hasher struct{}
+
+func (sha256Hasher) Size() int { return sha256.Size }
+
+func (sha256Hasher) HashLeaf(nsID, data []byte) []byte {
+	h := sha256.New()
+	h.Write([]byte{0x00})
+	h.Write(nsID)
+	h.Write(data)
+	return h.Sum(nil)
+}
+
+func (sha256Hasher) HashChildren(left, right Node) []byte {
+	h := sha256.New()
+	h.Write([]byte{0x01})
+	h.Write(left.MinNS)
+	h.Write(left.MaxNS)
+	h.Write(left.Hash)
+	h.Write(right.MinNS)
+	h.Write(right.MaxNS)
+	h.Write(right.Hash)
+	return h.Sum(nil)
+}
+
+// SHA256 is the default Hasher.
+var SHA256 Hasher = sha256Hasher{}
+
+// NodeVisitor is called for every internal node built while computing a
+// Tree's root, with the node itself and its two children, so callers can
+// stream inner nodes out to external storage instead of keeping the whole
+// tree in memory. It fires again, for every node, each time the root is
+// (re)computed.
+type NodeVisitor func(node, left, right Node)
+
+// Option configures a Tree built with New.
+type Option func(*options)
+
+type options struct {
+	hasher  Hasher
+	nsSize  int
+	visitor NodeVisitor
+}
+
+// WithHasher sets the Hasher a Tree is built and verified with. The default
+// is SHA256.
+func WithHasher(hasher Hasher) Option {
+	return func(o *options) { o.hasher = hasher }
+}
+
+// WithNodeVisitor sets a callback invoked for every internal node computed
+// while building the tree's root.
+func WithNodeVisitor(visitor NodeVisitor) Option {
+	return func(o *options) { o.visitor = visitor }
+}
+
+// Tree is a Namespaced Merkle Tree over a fixed-size namespace ID.
+type Tree struct {
+	nsSize  int
+	hasher  Hasher
+	visitor NodeVisitor
+
+	leaves []Node
+	lastNS []byte
+}
+
+// New creates an empty Tree whose namespace IDs are nsSize bytes long.
+func New(nsSize int, opts ...Option) *Tree {
+	o := &options{hasher: SHA256, nsSize: nsSize}
+	for _, opt := range opts {
+		opt(o)
+	}
+	return &Tree{nsSize: o.nsSize, hasher: o.hasher, visitor: o.visitor}
+}
+
+// Push appends a leaf with the given namespace ID and data. nsID must be
+// nsSize bytes long and must not be smaller than the namespace ID of the
+// previously pushed leaf, or ErrInvalidPushOrder is returned.
+func (t *Tree) Push(nsID, data []byte) error {
+	if len(nsID) != t.nsSize {
+		return errors.New("namespaced: namespace ID has the wrong size")
+	}
+	if t.lastNS != nil && bytes.Compare(nsID, t.lastNS) < 0 {
+		return ErrInvalidPushOrder
+	}
+
+	t.leaves = append(t.leaves, Node{MinNS: nsID, MaxNS: nsID, Hash: t.hasher.HashLeaf(nsID, data)})
+	t.lastNS = nsID
+	return nil
+}
+
+// LeafCount returns the number of leaves pushed to the tree.
+func (t *Tree) LeafCount() int {
+	return len(t.leaves)
+}
+
+// splitPoint returns the largest power of two strictly less than n.
+func splitPoint(n int) int {
+	return 1 << uint(bits.Len(uint(n-1))-1)
+}
+
+// build computes the Node covering nodes, splitting the same way New's
+// sibling packages do, and reporting every internal node it builds to the
+// tree's NodeVisitor.
+func (t *Tree) build(nodes []Node) Node {
+	if len(nodes) == 1 {
+		return nodes[0]
+	}
+
+	k := splitPoint(len(nodes))
+	left := t.build(nodes[:k])
+	right := t.build(nodes[k:])
+	node := combine(t.hasher, left, right)
+
+	if t.visitor != nil {
+		t.visitor(node, left, right)
+	}
+	return node
+}
+
+// combine folds two child Nodes into their parent Node.
+func combine(hasher Hasher, left, right Node) Node {
+	return Node{
+		MinNS: minBytes(left.MinNS, right.MinNS),
+		MaxNS: maxBytes(left.MaxNS, right.MaxNS),
+		Hash:  hasher.HashChildren(left, right),
+	}
+}
+
+func minBytes(a, b []byte) []byte {
+	if bytes.Compare(a, b) <= 0 {
+		return a
+	}
+	return b
+}
+
+func maxBytes(a, b []byte) []byte {
+	if bytes.Compare(a, b) >= 0 {
+		return a
+	}
+	return b
+}
+
+// Root returns the root Node of the tree, recomputing it (and, if a
+// NodeVisitor is configured, reporting every internal node to it) from the
+// pushed leaves.
+func (t *Tree) Root() Node {
+	if len(t.leaves) == 0 {
+		return Node{Hash: t.hasher.HashLeaf(nil, nil)}
+	}
+	return t.build(t.leaves)
+}
+
+// NamespaceProof proves either that nsID's leaves are exactly
+// [Start, End) of the tree (Start < End, a membership proof), or that no
+// leaf has nsID (Start == End, an absence proof anchored at the insertion
+// point nsID's leaves would occupy).
+type NamespaceProof struct {
+	N     int // total number of leaves in the tree the proof was taken from
+	Start int
+	End   int
+
+	// LeftBoundary and RightBoundary are the leaves immediately before
+	// Start and at End, populated only for absence proofs, so the verifier
+	// can check that they straddle nsID without needing their raw data.
+	LeftBoundary  *Node
+	RightBoundary *Node
+
+	// Siblings holds, in left-to-right order, the Node covering every
+	// maximal range of the tree disjoint from the proven range, as needed
+	// to fold the proven range's leaves up to the root.
+	Siblings []Node
+}
+
+// proveRange returns, in left-to-right order, the Node covering every
+// maximal sub-range of [lo, hi) disjoint from [start, end); sub-ranges
+// fully inside [start, end) need no proof, since the verifier can
+// recompute them from the leaf data it already has.
+func (t *Tree) proveRange(nodes []Node, lo, hi, start, end int) []Node {
+	if start <= lo && hi <= end {
+		return nil
+	}
+	if end <= lo || hi <= start {
+		return []Node{t.build(nodes[lo:hi])}
+	}
+
+	k := lo + splitPoint(hi-lo)
+	return append(t.proveRange(nodes, lo, k, start, end), t.proveRange(nodes, k, hi, start, end)...)
+}
+
+// ProveNamespace returns a NamespaceProof for nsID: a membership proof
+// naming the contiguous leaf range with that namespace, or an absence
+// proof if no leaf has it.
+func (t *Tree) ProveNamespace(nsID []byte) (*NamespaceProof, error) {
+	if len(nsID) != t.nsSize {
+		return nil, errors.New("namespaced: namespace ID has the wrong size")
+	}
+	if len(t.leaves) == 0 {
+		return nil, errors.New("namespaced: tree is empty")
+	}
+
+	start := sort.Search(len(t.leaves), func(i int) bool {
+		return bytes.Compare(t.leaves[i].MinNS, nsID) >= 0
+	})
+	end := start
+	for end < len(t.leaves) && bytes.Equal(t.leaves[end].MinNS, nsID) {
+		end++
+	}
+
+	p := &NamespaceProof{N: len(t.leaves), Start: start, End: end}
+	lo, hi := start, end
+	if start == end {
+		if start > 0 {
+			b := t.leaves[start-1]
+			p.LeftBoundary = &b
+			lo = start - 1
+		}
+		if end < len(t.leaves) {
+			b := t.leaves[end]
+			p.RightBoundary = &b
+			hi = end + 1
+		}
+	}
+	p.Siblings = t.proveRange(t.leaves, 0, len(t.leaves), lo, hi)
+	return p, nil
+}
+
+// VerifyNamespaceProof verifies proof using SHA256. For a membership proof,
+// leaves must hold the raw data of every leaf in [proof.Start, proof.End),
+// in order. For an absence proof, leaves must be empty. root is the Node
+// returned by the Tree's Root method. Use VerifyNamespaceProofWithHasher to
+// verify a proof from a tree built with a different Hasher.
+func VerifyNamespaceProof(proof *NamespaceProof, nsID []byte, leaves [][]byte, root Node) bool {
+	return VerifyNamespaceProofWithHasher(SHA256, proof, nsID, leaves, root)
+}
+
+// VerifyNamespaceProofWithHasher verifies a NamespaceProof produced by a
+// tree built with hasher. See VerifyNamespaceProof.
+func VerifyNamespaceProofWithHasher(hasher Hasher, proof *NamespaceProof, nsID []byte, leaves [][]byte, root Node) bool {
+	if proof == nil || proof.N <= 0 || proof.Start < 0 || proof.End < proof.Start || proof.End > proof.N {
+		return false
+	}
+
+	known := make(map[int]Node)
+	lo, hi := proof.Start, proof.End
+
+	if proof.Start < proof.End {
+		if len(leaves) != proof.End-proof.Start {
+			return false
+		}
+		for i, data := range leaves {
+			known[proof.Start+i] = Node{MinNS: nsID, MaxNS: nsID, Hash: hasher.HashLeaf(nsID, data)}
+		}
+	} else {
+		if len(leaves) != 0 {
+			return false
+		}
+		if proof.LeftBoundary == nil && proof.RightBoundary == nil && proof.N != 0 {
+			return false
+		}
+		if proof.LeftBoundary != nil {
+			if bytes.Compare(proof.LeftBoundary.MaxNS, nsID) >= 0 {
+				return false
+			}
+			lo = proof.Start - 1
+			known[lo] = *proof.LeftBoundary
+		}
+		if proof.RightBoundary != nil {
+			if bytes.Compare(proof.RightBoundary.MinNS, nsID) <= 0 {
+				return false
+			}
+			known[proof.End] = *proof.RightBoundary
+			hi = proof.End + 1
+		}
+	}
+
+	si := 0
+	got, ok := foldRange(hasher, nsID, known, 0, proof.N, lo, hi, proof.Siblings, &si)
+	if !ok || si != len(proof.Siblings) {
+		return false
+	}
+	return bytes.Equal(got.Hash, root.Hash)
+}
+
+// foldRange mirrors proveRange's recursion, folding [lo, hi) up from
+// either known (leaves inside the proven range) or the next unconsumed
+// entry of siblings (ranges disjoint from it). A sibling left of the proven
+// range must cover only namespaces below nsID and one right of it only
+// namespaces above nsID, so no nsID leaf lies outside the proven range.
+func foldRange(hasher Hasher, nsID []byte, known map[int]Node, lo, hi, start, end int, siblings []Node, si *int) (Node, bool) {
+	if start <= lo && hi <= end {
+		if hi-lo == 1 {
+			n, ok := known[lo]
+			return n, ok
+		}
+		k := lo + splitPoint(hi-lo)
+		left, ok := foldRange(hasher, nsID, known, lo, k, start, end, siblings, si)
+		if !ok {
+			return Node{}, false
+		}
+		right, ok := foldRange(hasher, nsID, known, k, hi, start, end, siblings, si)
+		if !ok {
+			return Node{}, false
+		}
+		return combine(hasher, left, right), true
+	}
+
+	if end <= lo || hi <= start {
+		if *si >= len(siblings) {
+			return Node{}, false
+		}
+		n := siblings[*si]
+		*si++
+		if hi <= start && bytes.Compare(n.MaxNS, nsID) >= 0 {
+			return Node{}, false
+		}
+		if end <= lo && bytes.Compare(n.MinNS, nsID) <= 0 {
+			return Node{}, false
+		}
+		return n, true
+	}
+
+	k := lo + splitPoint(hi-lo)
+	left, ok := foldRange(hasher, nsID, known, lo, k, start, end, siblings, si)
+	if !ok {
+		return Node{}, false
+	}
+	right, ok := foldRange(hasher, nsID, known, k, hi, start, end, siblings, si)
+	if !ok {
+		return Node{}, false
+	}
+	return combine(hasher, left, right), true
+}
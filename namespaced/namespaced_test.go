@@ -0,0 +1,148 @@
+/*
+ * Copyright © 2018 Lynn <lynn9388@gmail.com>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package namespaced
+
+import (
+	"bytes"
+	"testing"
+)
+
+// buildTestTree pushes one leaf per (namespace, data) pair, in order.
+func buildTestTree(t *testing.T, pairs [][2]string) *Tree {
+	t.Helper()
+	tr := New(1)
+	for _, p := range pairs {
+		if err := tr.Push([]byte(p[0]), []byte(p[1])); err != nil {
+			t.Fatalf("Push(%q, %q) error = %v", p[0], p[1], err)
+		}
+	}
+	return tr
+}
+
+func TestTree_PushOrder(t *testing.T) {
+	tr := New(1)
+	if err := tr.Push([]byte("b"), []byte("1")); err != nil {
+		t.Fatalf("Push() error = %v", err)
+	}
+	if err := tr.Push([]byte("a"), []byte("2")); err != ErrInvalidPushOrder {
+		t.Errorf("Push() with a decreasing namespace error = %v, want ErrInvalidPushOrder", err)
+	}
+	if err := tr.Push([]byte("b"), []byte("3")); err != nil {
+		t.Errorf("Push() with an equal namespace error = %v, want nil", err)
+	}
+}
+
+func TestTree_ProveNamespace_Membership(t *testing.T) {
+	pairs := [][2]string{
+		{"a", "a0"}, {"b", "b0"}, {"b", "b1"}, {"b", "b2"}, {"c", "c0"}, {"d", "d0"}, {"e", "e0"},
+	}
+	tr := buildTestTree(t, pairs)
+	root := tr.Root()
+
+	for _, ns := range []string{"a", "b", "c", "d", "e"} {
+		t.Run(ns, func(t *testing.T) {
+			proof, err := tr.ProveNamespace([]byte(ns))
+			if err != nil {
+				t.Fatalf("ProveNamespace(%q) error = %v", ns, err)
+			}
+			if proof.Start >= proof.End {
+				t.Fatalf("ProveNamespace(%q) = %+v, want a non-empty membership range", ns, proof)
+			}
+
+			var leaves [][]byte
+			for i := proof.Start; i < proof.End; i++ {
+				leaves = append(leaves, []byte(pairs[i][1]))
+			}
+
+			if !VerifyNamespaceProof(proof, []byte(ns), leaves, root) {
+				t.Errorf("VerifyNamespaceProof(%q) = false, want true", ns)
+			}
+			if len(leaves) > 1 && VerifyNamespaceProof(proof, []byte(ns), leaves[:len(leaves)-1], root) {
+				t.Errorf("VerifyNamespaceProof(%q) with a missing leaf = true, want false", ns)
+			}
+		})
+	}
+}
+
+func TestTree_ProveNamespace_Absence(t *testing.T) {
+	pairs := [][2]string{{"b", "b0"}, {"d", "d0"}, {"f", "f0"}}
+	tr := buildTestTree(t, pairs)
+	root := tr.Root()
+
+	for _, ns := range []string{"a", "c", "e", "g"} {
+		t.Run(ns, func(t *testing.T) {
+			proof, err := tr.ProveNamespace([]byte(ns))
+			if err != nil {
+				t.Fatalf("ProveNamespace(%q) error = %v", ns, err)
+			}
+			if proof.Start != proof.End {
+				t.Fatalf("ProveNamespace(%q) = %+v, want an absence proof", ns, proof)
+			}
+			if !VerifyNamespaceProof(proof, []byte(ns), nil, root) {
+				t.Errorf("VerifyNamespaceProof(%q) = false, want true", ns)
+			}
+			if VerifyNamespaceProof(proof, []byte("b"), nil, root) {
+				t.Errorf("VerifyNamespaceProof() for a present namespace using an absence proof = true, want false")
+			}
+		})
+	}
+}
+
+func TestTree_NodeVisitor(t *testing.T) {
+	var visited int
+	tr := New(1, WithNodeVisitor(func(node, left, right Node) {
+		visited++
+		if !bytes.Equal(node.Hash, combine(SHA256, left, right).Hash) {
+			t.Errorf("visited node hash does not match HashChildren(left, right)")
+		}
+	}))
+	for _, ns := range []string{"a", "b", "c", "d", "e"} {
+		if err := tr.Push([]byte(ns), []byte(ns)); err != nil {
+			t.Fatalf("Push(%q) error = %v", ns, err)
+		}
+	}
+	tr.Root()
+
+	if visited != tr.LeafCount()-1 {
+		t.Errorf("NodeVisitor fired %d times, want %d (one per internal node)", visited, tr.LeafCount()-1)
+	}
+}
+
+func TestVerifyNamespaceProof_Invalid(t *testing.T) {
+	pairs := [][2]string{{"a", "a0"}, {"b", "b0"}, {"c", "c0"}}
+	tr := buildTestTree(t, pairs)
+	root := tr.Root()
+
+	proof, err := tr.ProveNamespace([]byte("b"))
+	if err != nil {
+		t.Fatalf("ProveNamespace() error = %v", err)
+	}
+
+	if VerifyNamespaceProof(proof, []byte("b"), [][]byte{[]byte("wrong")}, root) {
+		t.Error("VerifyNamespaceProof() with wrong leaf data = true, want false")
+	}
+	if VerifyNamespaceProof(nil, []byte("b"), [][]byte{[]byte("b0")}, root) {
+		t.Error("VerifyNamespaceProof(nil) = true, want false")
+	}
+}
+
+func TestTree_Push_WrongSize(t *testing.T) {
+	tr := New(2)
+	if err := tr.Push([]byte("a"), []byte("data")); err == nil {
+		t.Error("Push() with a wrong-sized namespace ID expected error")
+	}
+}
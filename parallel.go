@@ -0,0 +1,144 @@
+/*
+ * Copyright © 2018 Lynn <lynn9388@gmail.com>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package merkletree
+
+import (
+	"context"
+	"errors"
+	"io"
+	"sync"
+)
+
+// NewMerkleTreeParallel builds a tree the same way NewMerkleTree does, but
+// shards leaf hashing and each level's reduction across workers goroutines,
+// for data slices too large for NewMerkleTree's single goroutine to build
+// efficiently. It uses SHA256; use NewMerkleTree for small inputs, where
+// the synchronization overhead outweighs the benefit. If ctx is cancelled
+// before the build finishes, it returns nil. workers < 1 is treated as 1.
+func NewMerkleTreeParallel(ctx context.Context, workers int, data ...[]byte) *MerkleTree {
+	if len(data) == 0 {
+		return nil
+	}
+	if workers < 1 {
+		workers = 1
+	}
+	hasher := SHA256
+
+	nodes := make([]*MerkleNode, len(data))
+	parallelFor(workers, len(data), func(i int) {
+		nodes[i] = newMerkleNode(hasher, nil, nil, data[i])
+	})
+	if ctx.Err() != nil {
+		return nil
+	}
+
+	leaves := make([][]byte, len(nodes))
+	for i, node := range nodes {
+		leaves[i] = node.Hash
+	}
+
+	for len(nodes) != 1 {
+		if ctx.Err() != nil {
+			return nil
+		}
+
+		pairs := len(nodes) / 2
+		parents := make([]*MerkleNode, pairs+len(nodes)%2)
+		parallelFor(workers, pairs, func(i int) {
+			parents[i] = newMerkleNode(hasher, nodes[2*i], nodes[2*i+1], nil)
+		})
+		if len(nodes)%2 != 0 {
+			parents[pairs] = nodes[len(nodes)-1]
+		}
+
+		nodes = parents
+	}
+
+	return &MerkleTree{Root: nodes[0], hasher: hasher, leaves: leaves}
+}
+
+// parallelFor calls fn(i) for every i in [0, n), spread across up to
+// workers goroutines, and waits for all of them to finish.
+func parallelFor(workers, n int, fn func(i int)) {
+	if n == 0 {
+		return
+	}
+	if workers > n {
+		workers = n
+	}
+	if workers <= 1 {
+		for i := 0; i < n; i++ {
+			fn(i)
+		}
+		return
+	}
+
+	chunk := (n + workers - 1) / workers
+	var wg sync.WaitGroup
+	for lo := 0; lo < n; lo += chunk {
+		hi := lo + chunk
+		if hi > n {
+			hi = n
+		}
+
+		wg.Add(1)
+		go func(lo, hi int) {
+			defer wg.Done()
+			for i := lo; i < hi; i++ {
+				fn(i)
+			}
+		}(lo, hi)
+	}
+	wg.Wait()
+}
+
+// NewMerkleTreeFromReader builds a tree the same way NewMerkleTree does,
+// reading leaves as consecutive chunkSize-byte chunks of r (the final
+// chunk may be shorter) instead of requiring the caller to already have
+// every leaf's data in memory at once. It uses SHA256.
+func NewMerkleTreeFromReader(r io.Reader, chunkSize int) (*MerkleTree, error) {
+	if chunkSize <= 0 {
+		return nil, errors.New("chunkSize must be positive")
+	}
+	hasher := SHA256
+
+	var nodes []*MerkleNode
+	var leaves [][]byte
+	buf := make([]byte, chunkSize)
+	for {
+		n, err := io.ReadFull(r, buf)
+		if n > 0 {
+			chunk := make([]byte, n)
+			copy(chunk, buf[:n])
+
+			node := newMerkleNode(hasher, nil, nil, chunk)
+			nodes = append(nodes, node)
+			leaves = append(leaves, node.Hash)
+		}
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	if len(nodes) == 0 {
+		return nil, errors.New("no data read from reader")
+	}
+
+	return &MerkleTree{Root: reduceToRoot(hasher, nodes), hasher: hasher, leaves: leaves}, nil
+}
@@ -0,0 +1,116 @@
+/*
+ * Copyright © 2018 Lynn <lynn9388@gmail.com>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package merkletree
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func testLeaves(n int) [][]byte {
+	data := make([][]byte, n)
+	for i := range data {
+		data[i] = []byte(fmt.Sprintf("leaf-%d", i))
+	}
+	return data
+}
+
+func TestNewMerkleTreeParallel(t *testing.T) {
+	for n := 1; n <= 20; n++ {
+		data := testLeaves(n)
+		want := NewMerkleTree(data...)
+
+		for _, workers := range []int{0, 1, 4} {
+			got := NewMerkleTreeParallel(context.Background(), workers, data...)
+			if !bytes.Equal(got.Root.Hash, want.Root.Hash) {
+				t.Errorf("NewMerkleTreeParallel(workers=%d, n=%d).Root.Hash = %x, want %x", workers, n, got.Root.Hash, want.Root.Hash)
+			}
+		}
+	}
+}
+
+func TestNewMerkleTreeParallel_NoData(t *testing.T) {
+	if mt := NewMerkleTreeParallel(context.Background(), 4); mt != nil {
+		t.Errorf("NewMerkleTreeParallel() with no data = %v, want nil", mt)
+	}
+}
+
+func TestNewMerkleTreeParallel_CancelledContext(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if mt := NewMerkleTreeParallel(ctx, 4, testLeaves(10)...); mt != nil {
+		t.Errorf("NewMerkleTreeParallel() with a cancelled context = %v, want nil", mt)
+	}
+}
+
+func TestNewMerkleTreeFromReader(t *testing.T) {
+	data := make([][]byte, 13)
+	for i := range data {
+		data[i] = []byte(fmt.Sprintf("leaf-%02d", i))
+	}
+	want := NewMerkleTree(data...)
+
+	r := strings.NewReader(strings.Join(sliceToStrings(data), ""))
+	got, err := NewMerkleTreeFromReader(r, len(data[0]))
+	if err != nil {
+		t.Fatalf("NewMerkleTreeFromReader() error = %v", err)
+	}
+	if !bytes.Equal(got.Root.Hash, want.Root.Hash) {
+		t.Errorf("NewMerkleTreeFromReader().Root.Hash = %x, want %x", got.Root.Hash, want.Root.Hash)
+	}
+	if got.LeafCount() != want.LeafCount() {
+		t.Errorf("NewMerkleTreeFromReader().LeafCount() = %d, want %d", got.LeafCount(), want.LeafCount())
+	}
+}
+
+func TestNewMerkleTreeFromReader_Errors(t *testing.T) {
+	if _, err := NewMerkleTreeFromReader(strings.NewReader("data"), 0); err == nil {
+		t.Error("NewMerkleTreeFromReader() with chunkSize 0 expected error")
+	}
+	if _, err := NewMerkleTreeFromReader(strings.NewReader(""), 4); err == nil {
+		t.Error("NewMerkleTreeFromReader() with empty reader expected error")
+	}
+}
+
+func sliceToStrings(data [][]byte) []string {
+	ss := make([]string, len(data))
+	for i, d := range data {
+		ss[i] = string(d)
+	}
+	return ss
+}
+
+func BenchmarkNewMerkleTree(b *testing.B) {
+	data := testLeaves(10000)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		NewMerkleTree(data...)
+	}
+}
+
+func BenchmarkNewMerkleTreeParallel(b *testing.B) {
+	data := testLeaves(10000)
+	ctx := context.Background()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		NewMerkleTreeParallel(ctx, 8, data...)
+	}
+}
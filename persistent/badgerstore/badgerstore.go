@@ -0,0 +1,104 @@
+/*
+ * Copyright © 2018 Lynn <lynn9388@gmail.com>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package badgerstore adapts a Badger database directory to
+// persistent.KVStore. It lives in its own module, separate from the main
+// github.com/lynn9388/merkletree module, because Badger pulls in a much
+// larger transitive dependency tree than the other adapters; consumers who
+// only need BoltDB or LevelDB should not have to fetch it.
+package badgerstore
+
+import (
+	badger "github.com/dgraph-io/badger/v4"
+
+	"github.com/lynn9388/merkletree/persistent"
+)
+
+// Store is a persistent.KVStore backed by a Badger database directory.
+type Store struct {
+	db *badger.DB
+}
+
+// Open opens (creating if necessary) the Badger database at path.
+func Open(path string) (*Store, error) {
+	db, err := badger.Open(badger.DefaultOptions(path))
+	if err != nil {
+		return nil, err
+	}
+	return &Store{db: db}, nil
+}
+
+// Close closes the underlying database.
+func (s *Store) Close() error {
+	return s.db.Close()
+}
+
+// Get implements persistent.KVStore.
+func (s *Store) Get(key []byte) ([]byte, error) {
+	var value []byte
+	err := s.db.View(func(txn *badger.Txn) error {
+		item, err := txn.Get(key)
+		if err == badger.ErrKeyNotFound {
+			return persistent.ErrNotFound
+		}
+		if err != nil {
+			return err
+		}
+		return item.Value(func(v []byte) error {
+			value = append([]byte(nil), v...)
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return value, nil
+}
+
+// Put implements persistent.KVStore.
+func (s *Store) Put(key, value []byte) error {
+	return s.db.Update(func(txn *badger.Txn) error {
+		return txn.Set(key, value)
+	})
+}
+
+// Delete implements persistent.KVStore.
+func (s *Store) Delete(key []byte) error {
+	return s.db.Update(func(txn *badger.Txn) error {
+		return txn.Delete(key)
+	})
+}
+
+// Batch implements persistent.KVStore.
+func (s *Store) Batch(ops []persistent.Op) error {
+	wb := s.db.NewWriteBatch()
+	defer wb.Cancel()
+	for _, op := range ops {
+		var err error
+		switch op.Kind {
+		case persistent.OpPut:
+			err = wb.Set(op.Key, op.Value)
+		case persistent.OpDelete:
+			err = wb.Delete(op.Key)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return wb.Flush()
+}
+
+var _ persistent.KVStore = (*Store)(nil)
@@ -0,0 +1,157 @@
+/*
+ * Copyright © 2018 Lynn <lynn9388@gmail.com>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package persistent
+
+import (
+	"encoding/binary"
+	"math/bits"
+	"runtime"
+	"sync"
+)
+
+// minBatchThreshold is the smallest AddBatch call for which hashing leaves
+// in parallel and writing them in one round trip pays for the extra
+// bookkeeping; smaller batches fall back to Append.
+const minBatchThreshold = 64
+
+// AddBatch adds data as new leaves, the same way Append does, but hashes
+// the leaves in parallel and merges all of them into the tree's right
+// spine in memory before issuing a single store.Batch call, instead of one
+// store round trip per leaf. For batches smaller than minBatchThreshold
+// leaves, it falls back to Append, where the extra bookkeeping would cost
+// more than it saves.
+func (t *Tree) AddBatch(data ...[]byte) ([]byte, error) {
+	if len(data) < minBatchThreshold {
+		return t.Append(data...)
+	}
+
+	leafHashes := make([][]byte, len(data))
+	parallelFor(runtime.NumCPU(), len(data), func(i int) {
+		leafHashes[i] = t.hasher.HashLeaf(data[i])
+	})
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	pending := make(map[string][]byte)
+	var ops []Op
+	put := func(level uint8, row uint64, value []byte) {
+		key := t.nodeKey(level, row)
+		pending[string(key)] = value
+		ops = append(ops, Op{Kind: OpPut, Key: key, Value: value})
+	}
+
+	for _, hash := range leafHashes {
+		row := t.size
+		priorSize := t.size
+		put(0, row, hash)
+
+		level := uint8(0)
+		for priorSize&(1<<level) != 0 {
+			sibling, err := t.rangeHashPending(pending, (row-1)<<level, uint64(1)<<level)
+			if err != nil {
+				return nil, err
+			}
+			hash = t.hasher.HashChildren(sibling, hash)
+			row >>= 1
+			level++
+			put(level, row, hash)
+		}
+		t.size++
+	}
+
+	var sizeBytes [8]byte
+	binary.BigEndian.PutUint64(sizeBytes[:], t.size)
+	ops = append(ops, Op{Kind: OpPut, Key: t.metaKey("size"), Value: sizeBytes[:]})
+
+	if err := t.store.Batch(ops); err != nil {
+		return nil, err
+	}
+	return t.root()
+}
+
+// rangeHashPending is rangeHash extended with an overlay of not-yet-flushed
+// nodes built earlier in the same AddBatch call, so later leaves in the
+// batch can merge with peaks the batch itself created without a store
+// round trip.
+func (t *Tree) rangeHashPending(pending map[string][]byte, lo, n uint64) ([]byte, error) {
+	if n == 1 {
+		if v, ok := pending[string(t.nodeKey(0, lo))]; ok {
+			return v, nil
+		}
+		return t.getNode(0, lo)
+	}
+	if n&(n-1) == 0 {
+		level := uint8(bits.Len64(n) - 1)
+		if v, ok := pending[string(t.nodeKey(level, lo>>level))]; ok {
+			return v, nil
+		}
+		hash, err := t.getNode(level, lo>>level)
+		if err == nil {
+			return hash, nil
+		}
+		if err != ErrNotFound {
+			return nil, err
+		}
+	}
+
+	k := uint64(splitPoint(n))
+	left, err := t.rangeHashPending(pending, lo, k)
+	if err != nil {
+		return nil, err
+	}
+	right, err := t.rangeHashPending(pending, lo+k, n-k)
+	if err != nil {
+		return nil, err
+	}
+	return t.hasher.HashChildren(left, right), nil
+}
+
+// parallelFor calls fn(i) for every i in [0, n), spread across up to
+// workers goroutines, and waits for all of them to finish.
+func parallelFor(workers, n int, fn func(i int)) {
+	if n == 0 {
+		return
+	}
+	if workers < 1 || workers > n {
+		workers = n
+	}
+	if workers <= 1 {
+		for i := 0; i < n; i++ {
+			fn(i)
+		}
+		return
+	}
+
+	chunk := (n + workers - 1) / workers
+	var wg sync.WaitGroup
+	for lo := 0; lo < n; lo += chunk {
+		hi := lo + chunk
+		if hi > n {
+			hi = n
+		}
+
+		wg.Add(1)
+		go func(lo, hi int) {
+			defer wg.Done()
+			for i := lo; i < hi; i++ {
+				fn(i)
+			}
+		}(lo, hi)
+	}
+	wg.Wait()
+}
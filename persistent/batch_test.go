@@ -0,0 +1,91 @@
+/*
+ * Copyright © 2018 Lynn <lynn9388@gmail.com>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package persistent
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestTree_AddBatch(t *testing.T) {
+	for _, n := range []int{1, 10, minBatchThreshold - 1, minBatchThreshold, minBatchThreshold + 50, 200} {
+		t.Run("", func(t *testing.T) {
+			data := leafData(n)
+
+			want, err := Open("want", Options{Store: NewMemStore()})
+			if err != nil {
+				t.Fatalf("Open() error = %v", err)
+			}
+			wantRoot, err := want.Append(data...)
+			if err != nil {
+				t.Fatalf("Append() error = %v", err)
+			}
+
+			got, err := Open("got", Options{Store: NewMemStore()})
+			if err != nil {
+				t.Fatalf("Open() error = %v", err)
+			}
+			gotRoot, err := got.AddBatch(data...)
+			if err != nil {
+				t.Fatalf("AddBatch() error = %v", err)
+			}
+
+			if !bytes.Equal(gotRoot, wantRoot) {
+				t.Fatalf("AddBatch(n=%d) root = %x, want %x", n, gotRoot, wantRoot)
+			}
+			if got.Size() != want.Size() {
+				t.Fatalf("AddBatch(n=%d) Size() = %d, want %d", n, got.Size(), want.Size())
+			}
+
+			for i := 0; i < n; i++ {
+				proof, err := got.Proof(uint64(i))
+				if err != nil {
+					t.Fatalf("Proof(%d) error = %v", i, err)
+				}
+				if !VerifyProof(uint64(i), got.Size(), data[i], proof, gotRoot) {
+					t.Errorf("VerifyProof(%d) after AddBatch(n=%d) = false, want true", i, n)
+				}
+			}
+		})
+	}
+}
+
+func TestTree_AddBatch_ThenAppend(t *testing.T) {
+	tr, err := Open("t", Options{Store: NewMemStore()})
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+
+	data := leafData(minBatchThreshold + 10)
+	if _, err := tr.AddBatch(data[:minBatchThreshold]...); err != nil {
+		t.Fatalf("AddBatch() error = %v", err)
+	}
+	root, err := tr.Append(data[minBatchThreshold:]...)
+	if err != nil {
+		t.Fatalf("Append() error = %v", err)
+	}
+
+	for i, datum := range data {
+		proof, err := tr.Proof(uint64(i))
+		if err != nil {
+			t.Fatalf("Proof(%d) error = %v", i, err)
+		}
+		if !VerifyProof(uint64(i), tr.Size(), datum, proof, root) {
+			t.Errorf("VerifyProof(%d) = false, want true", i)
+		}
+	}
+}
@@ -0,0 +1,106 @@
+/*
+ * Copyright © 2018 Lynn <lynn9388@gmail.com>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package boltstore adapts a BoltDB database file to persistent.KVStore.
+package boltstore
+
+import (
+	bolt "go.etcd.io/bbolt"
+
+	"github.com/lynn9388/merkletree/persistent"
+)
+
+var bucketName = []byte("persistent")
+
+// Store is a persistent.KVStore backed by a BoltDB database file.
+type Store struct {
+	db *bolt.DB
+}
+
+// Open opens (creating if necessary) the BoltDB database file at path.
+func Open(path string) (*Store, error) {
+	db, err := bolt.Open(path, 0600, nil)
+	if err != nil {
+		return nil, err
+	}
+	err = db.Update(func(tx *bolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists(bucketName)
+		return err
+	})
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	return &Store{db: db}, nil
+}
+
+// Close closes the underlying database file.
+func (s *Store) Close() error {
+	return s.db.Close()
+}
+
+// Get implements persistent.KVStore.
+func (s *Store) Get(key []byte) ([]byte, error) {
+	var value []byte
+	err := s.db.View(func(tx *bolt.Tx) error {
+		v := tx.Bucket(bucketName).Get(key)
+		if v == nil {
+			return persistent.ErrNotFound
+		}
+		value = append([]byte(nil), v...)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return value, nil
+}
+
+// Put implements persistent.KVStore.
+func (s *Store) Put(key, value []byte) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(bucketName).Put(key, value)
+	})
+}
+
+// Delete implements persistent.KVStore.
+func (s *Store) Delete(key []byte) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(bucketName).Delete(key)
+	})
+}
+
+// Batch implements persistent.KVStore.
+func (s *Store) Batch(ops []persistent.Op) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(bucketName)
+		for _, op := range ops {
+			var err error
+			switch op.Kind {
+			case persistent.OpPut:
+				err = b.Put(op.Key, op.Value)
+			case persistent.OpDelete:
+				err = b.Delete(op.Key)
+			}
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+var _ persistent.KVStore = (*Store)(nil)
@@ -0,0 +1,48 @@
+/*
+ * Copyright © 2018 Lynn <lynn9388@gmail.com>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package persistent
+
+import "crypto/sha256"
+
+// Hasher computes the leaf and internal node hashes of a Tree. It has the
+// same shape as the Hasher in the root and binary packages, so a Hasher
+// value from either can be used here too.
+type Hasher interface {
+	Size() int
+	HashLeaf(data []byte) []byte
+	HashChildren(left, right []byte) []byte
+}
+
+type sha256Hasher struct{}
+
+func (sha256Hasher) Size() int { return sha256.Size }
+
+func (sha256Hasher) HashLeaf(data []byte) []byte {
+	h := sha256.Sum256(data)
+	return h[:]
+}
+
+func (sha256Hasher) HashChildren(left, right []byte) []byte {
+	buf := make([]byte, 0, len(left)+len(right))
+	buf = append(buf, left...)
+	buf = append(buf, right...)
+	h := sha256.Sum256(buf)
+	return h[:]
+}
+
+// SHA256 is the default Hasher.
+var SHA256 Hasher = sha256Hasher{}
@@ -0,0 +1,82 @@
+/*
+ * Copyright © 2018 Lynn <lynn9388@gmail.com>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package leveldbstore adapts a LevelDB database directory to
+// persistent.KVStore.
+package leveldbstore
+
+import (
+	"github.com/syndtr/goleveldb/leveldb"
+
+	"github.com/lynn9388/merkletree/persistent"
+)
+
+// Store is a persistent.KVStore backed by a LevelDB database directory.
+type Store struct {
+	db *leveldb.DB
+}
+
+// Open opens (creating if necessary) the LevelDB database at path.
+func Open(path string) (*Store, error) {
+	db, err := leveldb.OpenFile(path, nil)
+	if err != nil {
+		return nil, err
+	}
+	return &Store{db: db}, nil
+}
+
+// Close closes the underlying database.
+func (s *Store) Close() error {
+	return s.db.Close()
+}
+
+// Get implements persistent.KVStore.
+func (s *Store) Get(key []byte) ([]byte, error) {
+	v, err := s.db.Get(key, nil)
+	if err == leveldb.ErrNotFound {
+		return nil, persistent.ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+// Put implements persistent.KVStore.
+func (s *Store) Put(key, value []byte) error {
+	return s.db.Put(key, value, nil)
+}
+
+// Delete implements persistent.KVStore.
+func (s *Store) Delete(key []byte) error {
+	return s.db.Delete(key, nil)
+}
+
+// Batch implements persistent.KVStore.
+func (s *Store) Batch(ops []persistent.Op) error {
+	batch := new(leveldb.Batch)
+	for _, op := range ops {
+		switch op.Kind {
+		case persistent.OpPut:
+			batch.Put(op.Key, op.Value)
+		case persistent.OpDelete:
+			batch.Delete(op.Key)
+		}
+	}
+	return s.db.Write(batch, nil)
+}
+
+var _ persistent.KVStore = (*Store)(nil)
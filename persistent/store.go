@@ -0,0 +1,117 @@
+/*
+ * Copyright © 2018 Lynn <lynn9388@gmail.com>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package persistent implements a Merkle tree whose nodes live in a
+// pluggable key/value store instead of an in-memory pointer tree, so it can
+// grow past what comfortably fits in memory. Appending a leaf only rewrites
+// the nodes on the path from the new leaf to the root (the "right spine"),
+// giving O(log n) work per append; see Tree.Append.
+package persistent
+
+import (
+	"errors"
+	"sync"
+)
+
+// ErrNotFound is returned by KVStore.Get when key is absent.
+var ErrNotFound = errors.New("persistent: key not found")
+
+// OpKind is the kind of mutation a Batch entry performs.
+type OpKind int
+
+const (
+	// OpPut writes Op.Value at Op.Key.
+	OpPut OpKind = iota
+	// OpDelete removes Op.Key.
+	OpDelete
+)
+
+// Op is a single mutation within a Batch call.
+type Op struct {
+	Kind  OpKind
+	Key   []byte
+	Value []byte
+}
+
+// KVStore is the storage backend a Tree persists its nodes to. Keys and
+// values are opaque byte strings; implementations need only byte-order
+// comparison, not any Merkle-tree-specific semantics. Adapters for BoltDB,
+// Badger and LevelDB are provided in the boltstore, badgerstore and
+// leveldbstore subpackages.
+type KVStore interface {
+	// Get returns the value stored at key, or ErrNotFound if key is absent.
+	Get(key []byte) ([]byte, error)
+	// Put writes value at key, overwriting any existing value.
+	Put(key, value []byte) error
+	// Delete removes key. Deleting an absent key is not an error.
+	Delete(key []byte) error
+	// Batch applies ops atomically, in order.
+	Batch(ops []Op) error
+}
+
+// MemStore is an in-memory KVStore, mainly useful for tests and for
+// running a Tree that does not need to survive a process restart.
+type MemStore struct {
+	mu   sync.RWMutex
+	data map[string][]byte
+}
+
+// NewMemStore creates an empty in-memory KVStore.
+func NewMemStore() *MemStore {
+	return &MemStore{data: make(map[string][]byte)}
+}
+
+// Get implements KVStore.
+func (s *MemStore) Get(key []byte) ([]byte, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	v, ok := s.data[string(key)]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return v, nil
+}
+
+// Put implements KVStore.
+func (s *MemStore) Put(key, value []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.data[string(key)] = value
+	return nil
+}
+
+// Delete implements KVStore.
+func (s *MemStore) Delete(key []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.data, string(key))
+	return nil
+}
+
+// Batch implements KVStore.
+func (s *MemStore) Batch(ops []Op) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, op := range ops {
+		switch op.Kind {
+		case OpPut:
+			s.data[string(op.Key)] = op.Value
+		case OpDelete:
+			delete(s.data, string(op.Key))
+		}
+	}
+	return nil
+}
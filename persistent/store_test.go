@@ -0,0 +1,74 @@
+/*
+ * Copyright © 2018 Lynn <lynn9388@gmail.com>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package persistent
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestMemStore(t *testing.T) {
+	s := NewMemStore()
+
+	if _, err := s.Get([]byte("a")); err != ErrNotFound {
+		t.Errorf("Get() on empty store error = %v, want ErrNotFound", err)
+	}
+
+	if err := s.Put([]byte("a"), []byte("1")); err != nil {
+		t.Fatalf("Put() error = %v", err)
+	}
+	v, err := s.Get([]byte("a"))
+	if err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+	if !bytes.Equal(v, []byte("1")) {
+		t.Errorf("Get() = %q, want %q", v, "1")
+	}
+
+	if err := s.Delete([]byte("a")); err != nil {
+		t.Fatalf("Delete() error = %v", err)
+	}
+	if _, err := s.Get([]byte("a")); err != ErrNotFound {
+		t.Errorf("Get() after Delete() error = %v, want ErrNotFound", err)
+	}
+}
+
+func TestMemStore_Batch(t *testing.T) {
+	s := NewMemStore()
+	if err := s.Put([]byte("a"), []byte("1")); err != nil {
+		t.Fatalf("Put() error = %v", err)
+	}
+
+	err := s.Batch([]Op{
+		{Kind: OpDelete, Key: []byte("a")},
+		{Kind: OpPut, Key: []byte("b"), Value: []byte("2")},
+	})
+	if err != nil {
+		t.Fatalf("Batch() error = %v", err)
+	}
+
+	if _, err := s.Get([]byte("a")); err != ErrNotFound {
+		t.Errorf("Get(a) after Batch() error = %v, want ErrNotFound", err)
+	}
+	v, err := s.Get([]byte("b"))
+	if err != nil {
+		t.Fatalf("Get(b) error = %v", err)
+	}
+	if !bytes.Equal(v, []byte("2")) {
+		t.Errorf("Get(b) = %q, want %q", v, "2")
+	}
+}
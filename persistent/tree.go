@@ -0,0 +1,450 @@
+/*
+ * Copyright © 2018 Lynn <lynn9388@gmail.com>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package persistent
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"math/bits"
+	"sort"
+	"sync"
+)
+
+// Options configures a Tree opened with Open.
+type Options struct {
+	// Store is the KVStore the tree's nodes and metadata are persisted
+	// to. Required. A single store may be shared by several trees opened
+	// at different paths.
+	Store KVStore
+
+	// Hasher is the hash function used for leaves and internal nodes.
+	// Defaults to SHA256.
+	Hasher Hasher
+
+	// KeepSnapshots bounds how many of the most recent Snapshot calls
+	// retain full inclusion proofs after Compact runs. Leaf hashes are
+	// never dropped. 0 disables compaction.
+	KeepSnapshots int
+}
+
+// Tree is a Merkle tree whose nodes are stored in a KVStore under a
+// level||index key layout, instead of an in-memory pointer tree. Append
+// only rewrites the path from the new leaf to the root (the right spine),
+// so it does O(log n) store operations regardless of tree size.
+type Tree struct {
+	store  KVStore
+	hasher Hasher
+	prefix []byte
+	opts   Options
+
+	mu   sync.RWMutex
+	size uint64
+}
+
+// Open opens (or creates) the tree stored at path within opts.Store. path
+// namespaces the tree's keys, so a single KVStore can hold several trees.
+func Open(path string, opts Options) (*Tree, error) {
+	if opts.Store == nil {
+		return nil, errors.New("persistent: Options.Store is required")
+	}
+	hasher := opts.Hasher
+	if hasher == nil {
+		hasher = SHA256
+	}
+
+	t := &Tree{
+		store:  opts.Store,
+		hasher: hasher,
+		prefix: append([]byte(path), 0),
+		opts:   opts,
+	}
+	if err := t.loadState(); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+func (t *Tree) loadState() error {
+	data, err := t.store.Get(t.metaKey("size"))
+	if err == ErrNotFound {
+		t.size = 0
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	t.size = binary.BigEndian.Uint64(data)
+	return nil
+}
+
+func (t *Tree) nodeKey(level uint8, row uint64) []byte {
+	key := make([]byte, 0, len(t.prefix)+10)
+	key = append(key, t.prefix...)
+	key = append(key, 'n', level)
+	var b [8]byte
+	binary.BigEndian.PutUint64(b[:], row)
+	return append(key, b[:]...)
+}
+
+func (t *Tree) metaKey(name string) []byte {
+	key := make([]byte, 0, len(t.prefix)+1+len(name))
+	key = append(key, t.prefix...)
+	key = append(key, 'm')
+	return append(key, name...)
+}
+
+func (t *Tree) snapshotKey(version uint64) []byte {
+	key := make([]byte, 0, len(t.prefix)+9)
+	key = append(key, t.prefix...)
+	key = append(key, 's')
+	var b [8]byte
+	binary.BigEndian.PutUint64(b[:], version)
+	return append(key, b[:]...)
+}
+
+func (t *Tree) getNode(level uint8, row uint64) ([]byte, error) {
+	return t.store.Get(t.nodeKey(level, row))
+}
+
+// Size returns the number of leaves appended to the tree.
+func (t *Tree) Size() uint64 {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	return t.size
+}
+
+// Append adds data as new leaves, in order, and returns the tree's root
+// hash after all of them have been added. Only the nodes on the path from
+// each new leaf to the root are read or written.
+func (t *Tree) Append(data ...[]byte) ([]byte, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	for _, datum := range data {
+		if err := t.appendOne(datum); err != nil {
+			return nil, err
+		}
+	}
+	return t.root()
+}
+
+// appendOne adds a single leaf, merging it with existing peaks the same
+// way a binary counter carries: a new leaf at level 0 merges with the
+// existing level-0 peak (if any) into a level-1 node, which merges with
+// the existing level-1 peak (if any), and so on, for as many levels as
+// t.size already has trailing set bits.
+func (t *Tree) appendOne(data []byte) error {
+	row := t.size
+	priorSize := t.size
+	hash := t.hasher.HashLeaf(data)
+
+	ops := []Op{{Kind: OpPut, Key: t.nodeKey(0, row), Value: hash}}
+
+	level := uint8(0)
+	for priorSize&(1<<level) != 0 {
+		// A direct lookup would normally do (this is always a maximal
+		// aligned block), but rangeHash also recomputes it from its
+		// leaves if Compact has dropped it since it was built.
+		sibling, err := t.rangeHash((row-1)<<level, uint64(1)<<level)
+		if err != nil {
+			return err
+		}
+		hash = t.hasher.HashChildren(sibling, hash)
+		row >>= 1
+		level++
+		ops = append(ops, Op{Kind: OpPut, Key: t.nodeKey(level, row), Value: hash})
+	}
+
+	t.size++
+	var sizeBytes [8]byte
+	binary.BigEndian.PutUint64(sizeBytes[:], t.size)
+	ops = append(ops, Op{Kind: OpPut, Key: t.metaKey("size"), Value: sizeBytes[:]})
+
+	return t.store.Batch(ops)
+}
+
+// Root returns the current root hash of the tree.
+func (t *Tree) Root() ([]byte, error) {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	return t.root()
+}
+
+// root computes the current root by folding the tree's peaks, the node
+// hashes of the maximal aligned power-of-two subtrees that partition
+// [0, size) - one per set bit of size, smallest first.
+func (t *Tree) root() ([]byte, error) {
+	if t.size == 0 {
+		return t.hasher.HashLeaf(nil), nil
+	}
+
+	// Peaks partition [0, size) into maximal aligned power-of-two blocks,
+	// one per set bit of size, largest first: the block at bit l starts
+	// where the previous (larger) block ended. rangeHash (rather than a
+	// direct node lookup) is used so a peak Compact has dropped is
+	// recomputed from its leaves instead of failing.
+	type span struct{ lo, n uint64 }
+	var peaks []span
+	base := uint64(0)
+	for l := bits.Len64(t.size) - 1; l >= 0; l-- {
+		if t.size&(1<<uint(l)) == 0 {
+			continue
+		}
+		n := uint64(1) << uint(l)
+		peaks = append(peaks, span{lo: base, n: n})
+		base += n
+	}
+
+	var acc []byte
+	for i := len(peaks) - 1; i >= 0; i-- {
+		hash, err := t.rangeHash(peaks[i].lo, peaks[i].n)
+		if err != nil {
+			return nil, err
+		}
+		if acc == nil {
+			acc = hash
+		} else {
+			acc = t.hasher.HashChildren(hash, acc)
+		}
+	}
+	return acc, nil
+}
+
+// rangeHash returns the hash of the (conceptual) subtree covering leaves
+// [lo, lo+n). Whenever that range is itself a maximal aligned power-of-two
+// subtree, its hash is usually a single stored node; otherwise (or if
+// Compact has dropped that node) it is folded from its own aligned
+// sub-ranges, the same way the merkletree package's mth does, bottoming
+// out at leaf hashes, which Compact never drops.
+func (t *Tree) rangeHash(lo, n uint64) ([]byte, error) {
+	if n == 1 {
+		return t.getNode(0, lo)
+	}
+	if n&(n-1) == 0 {
+		level := uint8(bits.Len64(n) - 1)
+		hash, err := t.getNode(level, lo>>level)
+		if err == nil {
+			return hash, nil
+		}
+		if err != ErrNotFound {
+			return nil, err
+		}
+	}
+
+	k := uint64(splitPoint(n))
+	left, err := t.rangeHash(lo, k)
+	if err != nil {
+		return nil, err
+	}
+	right, err := t.rangeHash(lo+k, n-k)
+	if err != nil {
+		return nil, err
+	}
+	return t.hasher.HashChildren(left, right), nil
+}
+
+// splitPoint returns the largest power of two strictly less than n.
+func splitPoint(n uint64) uint64 {
+	return uint64(1) << uint(bits.Len64(n-1)-1)
+}
+
+// Proof returns an inclusion proof for the leaf at index, as a list of
+// sibling hashes in leaf-to-root order. Use VerifyProof (with the same
+// index and the tree's current Size) to check it.
+func (t *Tree) Proof(index uint64) ([][]byte, error) {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	if index >= t.size {
+		return nil, errors.New("persistent: index out of range")
+	}
+	return t.auditPath(index, 0, t.size)
+}
+
+func (t *Tree) auditPath(index, lo, n uint64) ([][]byte, error) {
+	if n == 1 {
+		return nil, nil
+	}
+
+	k := splitPoint(n)
+	if index-lo < k {
+		path, err := t.auditPath(index, lo, k)
+		if err != nil {
+			return nil, err
+		}
+		sibling, err := t.rangeHash(lo+k, n-k)
+		if err != nil {
+			return nil, err
+		}
+		return append(path, sibling), nil
+	}
+
+	path, err := t.auditPath(index, lo+k, n-k)
+	if err != nil {
+		return nil, err
+	}
+	sibling, err := t.rangeHash(lo, k)
+	if err != nil {
+		return nil, err
+	}
+	return append(path, sibling), nil
+}
+
+// VerifyProof verifies that an inclusion proof returned by Proof(index)
+// authenticates data as the leaf at index, in a tree of size leaves with
+// the given root, using SHA256. Use VerifyProofWithHasher for a tree built
+// with a different Hasher.
+func VerifyProof(index, size uint64, data []byte, proof [][]byte, root []byte) bool {
+	return VerifyProofWithHasher(SHA256, index, size, data, proof, root)
+}
+
+// VerifyProofWithHasher verifies a proof produced by a tree built with
+// hasher. See VerifyProof.
+func VerifyProofWithHasher(hasher Hasher, index, size uint64, data []byte, proof [][]byte, root []byte) bool {
+	if size == 0 || index >= size {
+		return false
+	}
+
+	hash, rest, ok := verifyAuditPath(hasher, index, 0, size, hasher.HashLeaf(data), proof)
+	if !ok || len(rest) != 0 {
+		return false
+	}
+	return bytes.Equal(hash, root)
+}
+
+func verifyAuditPath(hasher Hasher, index, lo, n uint64, leafHash []byte, proof [][]byte) ([]byte, [][]byte, bool) {
+	if n == 1 {
+		return leafHash, proof, true
+	}
+
+	k := splitPoint(n)
+	if index-lo < k {
+		hash, rest, ok := verifyAuditPath(hasher, index, lo, k, leafHash, proof)
+		if !ok || len(rest) == 0 {
+			return nil, nil, false
+		}
+		return hasher.HashChildren(hash, rest[0]), rest[1:], true
+	}
+
+	hash, rest, ok := verifyAuditPath(hasher, index, lo+k, n-k, leafHash, proof)
+	if !ok || len(rest) == 0 {
+		return nil, nil, false
+	}
+	return hasher.HashChildren(rest[0], hash), rest[1:], true
+}
+
+// Snapshot records the tree's current size and root hash under version,
+// an identifier the caller assigns (e.g. a sequence number or timestamp),
+// so LoadSnapshot can later recall that historical root.
+func (t *Tree) Snapshot(version uint64) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	root, err := t.root()
+	if err != nil {
+		return err
+	}
+
+	var sizeBytes [8]byte
+	binary.BigEndian.PutUint64(sizeBytes[:], t.size)
+	value := append(sizeBytes[:], root...)
+	if err := t.store.Put(t.snapshotKey(version), value); err != nil {
+		return err
+	}
+	return t.recordSnapshotVersion(version)
+}
+
+// LoadSnapshot returns the size and root hash recorded by Snapshot(version).
+func (t *Tree) LoadSnapshot(version uint64) (root []byte, size uint64, err error) {
+	data, err := t.store.Get(t.snapshotKey(version))
+	if err != nil {
+		return nil, 0, err
+	}
+	return data[8:], binary.BigEndian.Uint64(data[:8]), nil
+}
+
+func (t *Tree) listSnapshotVersions() ([]uint64, error) {
+	data, err := t.store.Get(t.metaKey("snapshots"))
+	if err == ErrNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	versions := make([]uint64, len(data)/8)
+	for i := range versions {
+		versions[i] = binary.BigEndian.Uint64(data[i*8:])
+	}
+	return versions, nil
+}
+
+func (t *Tree) recordSnapshotVersion(version uint64) error {
+	versions, err := t.listSnapshotVersions()
+	if err != nil {
+		return err
+	}
+	versions = append(versions, version)
+	sort.Slice(versions, func(i, j int) bool { return versions[i] < versions[j] })
+
+	buf := make([]byte, 0, len(versions)*8)
+	for _, v := range versions {
+		var b [8]byte
+		binary.BigEndian.PutUint64(b[:], v)
+		buf = append(buf, b[:]...)
+	}
+	return t.store.Put(t.metaKey("snapshots"), buf)
+}
+
+// Compact drops intermediate (non-leaf) node hashes fully covered by
+// versions older than the most recent Options.KeepSnapshots, trading
+// storage for the speed of serving proofs over those older ranges: Proof
+// and Root still produce correct results afterwards, since rangeHash falls
+// back to recomputing a dropped node from the leaf hashes underneath it,
+// but that recomputation is O(range size) instead of O(1). Leaf hashes are
+// never dropped. A no-op if KeepSnapshots <= 0.
+func (t *Tree) Compact() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.opts.KeepSnapshots <= 0 {
+		return nil
+	}
+
+	versions, err := t.listSnapshotVersions()
+	if err != nil {
+		return err
+	}
+	if len(versions) <= t.opts.KeepSnapshots {
+		return nil
+	}
+
+	_, cutoffSize, err := t.LoadSnapshot(versions[len(versions)-t.opts.KeepSnapshots-1])
+	if err != nil {
+		return err
+	}
+
+	var ops []Op
+	for level := 1; level < bits.Len64(t.size); level++ {
+		span := uint64(1) << uint(level)
+		for row := uint64(0); (row+1)*span <= cutoffSize; row++ {
+			ops = append(ops, Op{Kind: OpDelete, Key: t.nodeKey(uint8(level), row)})
+		}
+	}
+	return t.store.Batch(ops)
+}
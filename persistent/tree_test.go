@@ -0,0 +1,239 @@
+/*
+ * Copyright © 2018 Lynn <lynn9388@gmail.com>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package persistent
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+)
+
+func leafData(n int) [][]byte {
+	data := make([][]byte, n)
+	for i := range data {
+		data[i] = []byte(fmt.Sprintf("leaf-%d", i))
+	}
+	return data
+}
+
+func TestTree_AppendAndProof(t *testing.T) {
+	for n := 1; n <= 20; n++ {
+		n := n
+		t.Run("", func(t *testing.T) {
+			tr, err := Open("t", Options{Store: NewMemStore()})
+			if err != nil {
+				t.Fatalf("Open() error = %v", err)
+			}
+
+			data := leafData(n)
+			root, err := tr.Append(data...)
+			if err != nil {
+				t.Fatalf("Append() error = %v", err)
+			}
+			if tr.Size() != uint64(n) {
+				t.Errorf("Size() = %d, want %d", tr.Size(), n)
+			}
+
+			for i := 0; i < n; i++ {
+				proof, err := tr.Proof(uint64(i))
+				if err != nil {
+					t.Fatalf("Proof(%d) error = %v", i, err)
+				}
+				if !VerifyProof(uint64(i), uint64(n), data[i], proof, root) {
+					t.Errorf("VerifyProof(%d) = false, want true", i)
+				}
+				if VerifyProof(uint64(i), uint64(n), []byte("wrong"), proof, root) {
+					t.Errorf("VerifyProof(%d) with wrong data = true, want false", i)
+				}
+			}
+		})
+	}
+}
+
+func TestTree_Open(t *testing.T) {
+	store := NewMemStore()
+	tr, err := Open("t", Options{Store: store})
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	data := leafData(5)
+	root, err := tr.Append(data...)
+	if err != nil {
+		t.Fatalf("Append() error = %v", err)
+	}
+
+	reopened, err := Open("t", Options{Store: store})
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	if reopened.Size() != tr.Size() {
+		t.Errorf("reopened Size() = %d, want %d", reopened.Size(), tr.Size())
+	}
+	reopenedRoot, err := reopened.Root()
+	if err != nil {
+		t.Fatalf("Root() error = %v", err)
+	}
+	if !bytes.Equal(reopenedRoot, root) {
+		t.Errorf("reopened Root() = %x, want %x", reopenedRoot, root)
+	}
+}
+
+func TestTree_Proof_OutOfRange(t *testing.T) {
+	tr, _ := Open("t", Options{Store: NewMemStore()})
+	if _, err := tr.Append(leafData(3)...); err != nil {
+		t.Fatalf("Append() error = %v", err)
+	}
+	if _, err := tr.Proof(3); err == nil {
+		t.Error("Proof(3) with 3 leaves expected error")
+	}
+}
+
+func TestVerifyProof_Invalid(t *testing.T) {
+	tr, _ := Open("t", Options{Store: NewMemStore()})
+	data := leafData(8)
+	root, err := tr.Append(data...)
+	if err != nil {
+		t.Fatalf("Append() error = %v", err)
+	}
+	proof, err := tr.Proof(5)
+	if err != nil {
+		t.Fatalf("Proof() error = %v", err)
+	}
+
+	if VerifyProof(5, 8, data[5], proof, []byte("wrong root")) {
+		t.Error("VerifyProof() = true for a wrong root, want false")
+	}
+	if len(proof) > 0 && VerifyProof(5, 8, data[5], proof[:len(proof)-1], root) {
+		t.Error("VerifyProof() = true for a truncated proof, want false")
+	}
+	if VerifyProof(8, 8, data[0], proof, root) {
+		t.Error("VerifyProof() = true for an out-of-range index, want false")
+	}
+}
+
+func TestTree_SnapshotAndLoadSnapshot(t *testing.T) {
+	tr, _ := Open("t", Options{Store: NewMemStore()})
+
+	var roots [][]byte
+	data := leafData(10)
+	for v, datum := range data {
+		root, err := tr.Append(datum)
+		if err != nil {
+			t.Fatalf("Append() error = %v", err)
+		}
+		roots = append(roots, root)
+		if err := tr.Snapshot(uint64(v)); err != nil {
+			t.Fatalf("Snapshot(%d) error = %v", v, err)
+		}
+	}
+
+	for v := range data {
+		root, size, err := tr.LoadSnapshot(uint64(v))
+		if err != nil {
+			t.Fatalf("LoadSnapshot(%d) error = %v", v, err)
+		}
+		if size != uint64(v+1) {
+			t.Errorf("LoadSnapshot(%d) size = %d, want %d", v, size, v+1)
+		}
+		if !bytes.Equal(root, roots[v]) {
+			t.Errorf("LoadSnapshot(%d) root = %x, want %x", v, root, roots[v])
+		}
+	}
+
+	if _, _, err := tr.LoadSnapshot(999); err != ErrNotFound {
+		t.Errorf("LoadSnapshot(999) error = %v, want ErrNotFound", err)
+	}
+}
+
+func TestTree_Compact(t *testing.T) {
+	tr, err := Open("t", Options{Store: NewMemStore(), KeepSnapshots: 2})
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+
+	const n = 20
+	data := leafData(n)
+	for v, datum := range data {
+		if _, err := tr.Append(datum); err != nil {
+			t.Fatalf("Append() error = %v", err)
+		}
+		if err := tr.Snapshot(uint64(v)); err != nil {
+			t.Fatalf("Snapshot(%d) error = %v", v, err)
+		}
+	}
+
+	if err := tr.Compact(); err != nil {
+		t.Fatalf("Compact() error = %v", err)
+	}
+
+	root, err := tr.Root()
+	if err != nil {
+		t.Fatalf("Root() error = %v", err)
+	}
+	for i := 0; i < n; i++ {
+		proof, err := tr.Proof(uint64(i))
+		if err != nil {
+			t.Fatalf("Proof(%d) after Compact() error = %v", i, err)
+		}
+		if !VerifyProof(uint64(i), n, data[i], proof, root) {
+			t.Errorf("VerifyProof(%d) after Compact() = false, want true", i)
+		}
+	}
+}
+
+func TestTree_CompactThenAppend(t *testing.T) {
+	tr, err := Open("t", Options{Store: NewMemStore(), KeepSnapshots: 1})
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+
+	data := leafData(32)
+	if _, err := tr.Append(data[:16]...); err != nil {
+		t.Fatalf("Append() error = %v", err)
+	}
+	if err := tr.Snapshot(0); err != nil {
+		t.Fatalf("Snapshot(0) error = %v", err)
+	}
+	if err := tr.Snapshot(1); err != nil {
+		t.Fatalf("Snapshot(1) error = %v", err)
+	}
+	if err := tr.Compact(); err != nil {
+		t.Fatalf("Compact() error = %v", err)
+	}
+
+	// Appending past the compacted peak forces a merge that must fall
+	// back to recomputing it from its leaves.
+	root, err := tr.Append(data[16:]...)
+	if err != nil {
+		t.Fatalf("Append() error = %v", err)
+	}
+	for i := range data {
+		proof, err := tr.Proof(uint64(i))
+		if err != nil {
+			t.Fatalf("Proof(%d) error = %v", i, err)
+		}
+		if !VerifyProof(uint64(i), uint64(len(data)), data[i], proof, root) {
+			t.Errorf("VerifyProof(%d) = false, want true", i)
+		}
+	}
+}
+
+func TestOpen_NoStore(t *testing.T) {
+	if _, err := Open("t", Options{}); err == nil {
+		t.Error("Open() with no Store expected error")
+	}
+}
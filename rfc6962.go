@@ -0,0 +1,28 @@
+/*
+ * Copyright © 2018 Lynn <lynn9388@gmail.com>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package merkletree
+
+import "github.com/lynn9388/merkletree/rfc6962"
+
+// NewMerkleTreeRFC6962 builds a new Merkle tree using the data with the
+// domain-separated leaf/node hashing scheme defined by RFC 6962
+// (Certificate Transparency). Unlike NewMerkleTree, the resulting tree is
+// immune to second-preimage attacks where an interior node's children are
+// reinterpreted as leaf data.
+func NewMerkleTreeRFC6962(data ...[]byte) *rfc6962.MerkleTree {
+	return rfc6962.New(data...)
+}
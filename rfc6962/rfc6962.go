@@ -0,0 +1,170 @@
+/*
+ * Copyright © 2018 Lynn <lynn9388@gmail.com>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package rfc6962 implements a Merkle hash tree using the domain-separated
+// leaf/node hashing scheme defined by RFC 6962 (Certificate Transparency),
+// which prevents an interior node's children from being reinterpreted as
+// leaf data (a second-preimage attack).
+package rfc6962
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"errors"
+	"math"
+)
+
+const (
+	left = iota
+	right
+
+	leafHashPrefix = 0x00
+	nodeHashPrefix = 0x01
+)
+
+// MerkleTree is a binary tree with RFC 6962 domain-separated hash values.
+type MerkleTree struct {
+	Parent *MerkleTree
+	Left   *MerkleTree
+	Right  *MerkleTree
+	Hash   []byte
+}
+
+// AuditPath is the shortest list of additional nodes in the Merkle tree
+// required to compute the root hash for that tree.
+type AuditPath struct {
+	Path  [][]byte
+	Order []int
+}
+
+// hashEmpty returns the hash of an empty tree, H().
+func hashEmpty() []byte {
+	hash := sha256.Sum256(nil)
+	return hash[:]
+}
+
+// hashLeaf returns H(0x00 || data).
+func hashLeaf(data []byte) []byte {
+	hash := sha256.Sum256(append([]byte{leafHashPrefix}, data...))
+	return hash[:]
+}
+
+// hashChildren returns H(0x01 || left || right).
+func hashChildren(l, r []byte) []byte {
+	buf := append([]byte{nodeHashPrefix}, l...)
+	buf = append(buf, r...)
+	hash := sha256.Sum256(buf)
+	return hash[:]
+}
+
+// split returns the largest power of two strictly less than n, as required
+// by RFC 6962 for splitting a range of n > 1 leaves.
+func split(n int) int {
+	return int(math.Exp2(math.Ceil(math.Log2(float64(n)) - 1)))
+}
+
+// New builds a new RFC 6962 Merkle tree using the data. If the data is
+// empty then the hash value of the root node is H(), the hash of the
+// empty string.
+func New(data ...[]byte) *MerkleTree {
+	n := len(data)
+	if n == 0 {
+		return &MerkleTree{Hash: hashEmpty()}
+	}
+
+	var subTree func([][]byte) *MerkleTree
+	subTree = func(data [][]byte) *MerkleTree {
+		n := len(data)
+
+		// leaf node
+		if n == 1 {
+			return &MerkleTree{Hash: hashLeaf(data[0])}
+		}
+
+		parent := &MerkleTree{}
+		k := split(n)
+
+		l := subTree(data[0:k])
+		r := subTree(data[k:n])
+		l.Parent = parent
+		r.Parent = parent
+
+		parent.Left = l
+		parent.Right = r
+		parent.Hash = hashChildren(l.Hash, r.Hash)
+
+		return parent
+	}
+	return subTree(data)
+}
+
+// findLeaf finds the leaf node with the same hash value. If not found then
+// nil will be returned.
+func (mt *MerkleTree) findLeaf(hash []byte) *MerkleTree {
+	if mt == nil {
+		return nil
+	}
+
+	if mt.Left == nil && mt.Right == nil && bytes.Equal(mt.Hash, hash) {
+		return mt
+	}
+
+	leaf := mt.Left.findLeaf(hash)
+	if leaf == nil {
+		leaf = mt.Right.findLeaf(hash)
+	}
+	return leaf
+}
+
+// GetAuditPath returns a Merkle audit path for a leaf node. The audit path
+// proofs the hash value of the data belongs to a leaf node.
+func (mt *MerkleTree) GetAuditPath(data []byte) (*AuditPath, error) {
+	node := mt.findLeaf(hashLeaf(data))
+	if node == nil {
+		return nil, errors.New("failed to find leaf node")
+	}
+
+	ap := &AuditPath{}
+	for !bytes.Equal(node.Hash, mt.Hash) {
+		if node.Parent.Left == node {
+			ap.Path = append(ap.Path, node.Parent.Right.Hash)
+			ap.Order = append(ap.Order, right)
+		} else {
+			ap.Path = append(ap.Path, node.Parent.Left.Hash)
+			ap.Order = append(ap.Order, left)
+		}
+		node = node.Parent
+	}
+	return ap, nil
+}
+
+// IsValid checks if an audit path is valid (the data's hash is a leaf of
+// the Merkle hash tree), folding siblings with the RFC 6962 node prefix.
+func (ap *AuditPath) IsValid(data []byte, rootHash []byte) bool {
+	if ap == nil {
+		return false
+	}
+
+	h := hashLeaf(data)
+	for i, p := range ap.Path {
+		if ap.Order[i] == left {
+			h = hashChildren(p, h)
+		} else {
+			h = hashChildren(h, p)
+		}
+	}
+	return bytes.Equal(rootHash, h)
+}
@@ -0,0 +1,107 @@
+/*
+ * Copyright © 2018 Lynn <lynn9388@gmail.com>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package rfc6962
+
+import (
+	"encoding/hex"
+	"reflect"
+	"testing"
+)
+
+// Known answer values from the RFC 6962 / Trillian test vectors.
+const (
+	emptyRootHash = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+	emptyLeafHash = "6e340b9cffb37a989ca544e6bb780a2c78901d3fb33738768511a30617afa01d"
+)
+
+func TestNewEmpty(t *testing.T) {
+	got := hex.EncodeToString(New().Hash)
+	if got != emptyRootHash {
+		t.Errorf("New().Hash = %v, want %v", got, emptyRootHash)
+	}
+}
+
+func TestHashLeafEmpty(t *testing.T) {
+	got := hex.EncodeToString(hashLeaf(nil))
+	if got != emptyLeafHash {
+		t.Errorf("hashLeaf(nil) = %v, want %v", got, emptyLeafHash)
+	}
+}
+
+func TestNew(t *testing.T) {
+	aHash := hashLeaf([]byte("a"))
+	bHash := hashLeaf([]byte("b"))
+	cHash := hashLeaf([]byte("c"))
+	dHash := hashLeaf([]byte("d"))
+	abHash := hashChildren(aHash, bHash)
+	cdHash := hashChildren(cHash, dHash)
+	abcHash := hashChildren(abHash, cHash)
+	abcdHash := hashChildren(abHash, cdHash)
+
+	tests := []struct {
+		name string
+		data [][]byte
+		want []byte
+	}{
+		{"a", [][]byte{[]byte("a")}, aHash},
+		{"ab", [][]byte{[]byte("a"), []byte("b")}, abHash},
+		{"abc", [][]byte{[]byte("a"), []byte("b"), []byte("c")}, abcHash},
+		{"abcd", [][]byte{[]byte("a"), []byte("b"), []byte("c"), []byte("d")}, abcdHash},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := New(tt.data...).Hash; !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("New().Hash = %x, want %x", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMerkleTree_GetAuditPath(t *testing.T) {
+	tests := [][]byte{[]byte("a"), []byte("b"), []byte("c")}
+	mt := New(tests...)
+
+	ap, err := mt.GetAuditPath(tests[1])
+	if err != nil {
+		t.Fatalf("GetAuditPath() error = %v", err)
+	}
+	if !ap.IsValid(tests[1], mt.Hash) {
+		t.Error("IsValid() = false, want true")
+	}
+
+	if _, err := mt.GetAuditPath([]byte("z")); err == nil {
+		t.Error("GetAuditPath() expected error for missing leaf")
+	}
+}
+
+func TestAuditPath_IsValid(t *testing.T) {
+	tests := [][]byte{[]byte("a"), []byte("b"), []byte("c"), []byte("d"), []byte("e")}
+	mt := New(tests...)
+
+	for _, data := range tests {
+		ap, err := mt.GetAuditPath(data)
+		if err != nil {
+			t.Fatalf("GetAuditPath(%s) error = %v", data, err)
+		}
+		if !ap.IsValid(data, mt.Hash) {
+			t.Errorf("IsValid(%s) = false, want true", data)
+		}
+		if ap.IsValid([]byte("wrong"), mt.Hash) {
+			t.Errorf("IsValid(wrong) = true, want false")
+		}
+	}
+}
@@ -0,0 +1,237 @@
+/*
+ * Copyright © 2018 Lynn <lynn9388@gmail.com>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package sparse implements a sparse Merkle tree: an authenticated
+// key/value map over a fixed-depth key space (one bit of depth per bit of
+// the hash function's output) that can prove both membership and
+// non-membership of a key. Only non-default subtrees are stored, so a tree
+// holding n entries uses O(n log n) storage and O(log n) proof size even
+// though the key space itself is astronomically large.
+package sparse
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"errors"
+)
+
+// Hasher is the hash function a Tree is built with. Size determines the
+// depth of the tree in bits.
+type Hasher interface {
+	Size() int
+	Sum(data []byte) []byte
+}
+
+type sha256Hasher struct{}
+
+func (sha256Hasher) Size() int { return sha256.Size }
+
+func (sha256Hasher) Sum(data []byte) []byte {
+	hash := sha256.Sum256(data)
+	return hash[:]
+}
+
+// SHA256 is a Hasher backed by crypto/sha256, giving a 256-bit deep tree.
+var SHA256 Hasher = sha256Hasher{}
+
+// Tree is a sparse Merkle tree over a fixed-depth key space.
+type Tree struct {
+	hasher Hasher
+	depth  int
+
+	// defaultHash[d] is the hash of an empty subtree rooted at depth d,
+	// with defaultHash[depth] being the hash of an absent leaf.
+	defaultHash [][]byte
+
+	// nodes stores the two children of every non-default node, keyed by
+	// the node's own hash. Default subtrees are never stored.
+	nodes map[string][2][]byte
+
+	root []byte
+}
+
+// Proof is a Merkle proof of membership or non-membership of a key. It
+// holds one sibling hash per level of the tree, ordered from the root down
+// to the leaf.
+type Proof struct {
+	Siblings [][]byte
+}
+
+// New creates an empty sparse Merkle tree using hash.
+func New(hash Hasher) *Tree {
+	depth := hash.Size() * 8
+
+	defaultHash := make([][]byte, depth+1)
+	defaultHash[depth] = hash.Sum(nil)
+	for d := depth - 1; d >= 0; d-- {
+		defaultHash[d] = hash.Sum(concat(defaultHash[d+1], defaultHash[d+1]))
+	}
+
+	return &Tree{
+		hasher:      hash,
+		depth:       depth,
+		defaultHash: defaultHash,
+		nodes:       make(map[string][2][]byte),
+		root:        defaultHash[0],
+	}
+}
+
+func concat(a, b []byte) []byte {
+	buf := make([]byte, 0, len(a)+len(b))
+	buf = append(buf, a...)
+	buf = append(buf, b...)
+	return buf
+}
+
+// bitAt returns the i-th bit of hash, counting from the most significant
+// bit of the first byte.
+func bitAt(hash []byte, i int) int {
+	return int(hash[i/8]>>(7-uint(i%8))) & 1
+}
+
+// leafHash returns the hash stored at key's leaf. A nil value yields the
+// default (absent) leaf hash.
+func (t *Tree) leafHash(key, value []byte) []byte {
+	if value == nil {
+		return t.defaultHash[t.depth]
+	}
+	return t.hasher.Sum(concat(key, value))
+}
+
+// children returns the two children of the node with the given hash at
+// depth d, falling back to the default subtree when the node was never
+// stored.
+func (t *Tree) children(hash []byte, d int) (left, right []byte) {
+	if bytes.Equal(hash, t.defaultHash[d]) {
+		return t.defaultHash[d+1], t.defaultHash[d+1]
+	}
+	n := t.nodes[string(hash)]
+	return n[0], n[1]
+}
+
+// hashPair returns the hash of a node at depth d with the given children,
+// collapsing back to the default hash when both children are themselves
+// default, so default subtrees are never stored.
+func (t *Tree) hashPair(left, right []byte, d int) []byte {
+	if bytes.Equal(left, t.defaultHash[d+1]) && bytes.Equal(right, t.defaultHash[d+1]) {
+		return t.defaultHash[d]
+	}
+	return t.hasher.Sum(concat(left, right))
+}
+
+// path walks from the root down to key's leaf, returning the sibling hash
+// at every depth in root-to-leaf order.
+func (t *Tree) path(key []byte) [][]byte {
+	hash := t.hasher.Sum(key)
+	siblings := make([][]byte, t.depth)
+
+	cur := t.root
+	for d := 0; d < t.depth; d++ {
+		left, right := t.children(cur, d)
+		if bitAt(hash, d) == 0 {
+			siblings[d] = right
+			cur = left
+		} else {
+			siblings[d] = left
+			cur = right
+		}
+	}
+	return siblings
+}
+
+// Update sets the value stored at key, recomputing the root. A nil value
+// is equivalent to Delete.
+//
+// No version history is kept, so the old node on key's path at each depth
+// is reclaimed as soon as the new path makes it unreachable, keeping
+// storage at O(n log n) in the number of live keys rather than growing
+// with the number of Update calls.
+func (t *Tree) Update(key, value []byte) {
+	hash := t.hasher.Sum(key)
+
+	siblings := make([][]byte, t.depth)
+	oldNodes := make([][]byte, t.depth)
+	cur := t.root
+	for d := 0; d < t.depth; d++ {
+		oldNodes[d] = cur
+		left, right := t.children(cur, d)
+		if bitAt(hash, d) == 0 {
+			siblings[d] = right
+			cur = left
+		} else {
+			siblings[d] = left
+			cur = right
+		}
+	}
+
+	node := t.leafHash(key, value)
+	for d := t.depth - 1; d >= 0; d-- {
+		var left, right []byte
+		if bitAt(hash, d) == 0 {
+			left, right = node, siblings[d]
+		} else {
+			left, right = siblings[d], node
+		}
+
+		node = t.hashPair(left, right, d)
+		if !bytes.Equal(node, t.defaultHash[d]) {
+			t.nodes[string(node)] = [2][]byte{left, right}
+		}
+		if old := oldNodes[d]; !bytes.Equal(old, node) {
+			delete(t.nodes, string(old))
+		}
+	}
+	t.root = node
+}
+
+// Delete removes key from the tree, recomputing the root.
+func (t *Tree) Delete(key []byte) {
+	t.Update(key, nil)
+}
+
+// Root returns the current root hash of the tree.
+func (t *Tree) Root() []byte {
+	return t.root
+}
+
+// Prove returns a Merkle proof for key. The same proof is used to verify
+// both membership (value != nil) and non-membership (value == nil); see
+// VerifyProof.
+func (t *Tree) Prove(key []byte) (*Proof, error) {
+	if key == nil {
+		return nil, errors.New("key must not be nil")
+	}
+	return &Proof{Siblings: t.path(key)}, nil
+}
+
+// VerifyProof checks that p proves key maps to value (value == nil proves
+// that key is absent) under root.
+func (t *Tree) VerifyProof(root, key, value []byte, p *Proof) bool {
+	if p == nil || len(p.Siblings) != t.depth {
+		return false
+	}
+
+	hash := t.hasher.Sum(key)
+	node := t.leafHash(key, value)
+	for d := t.depth - 1; d >= 0; d-- {
+		if bitAt(hash, d) == 0 {
+			node = t.hashPair(node, p.Siblings[d], d)
+		} else {
+			node = t.hashPair(p.Siblings[d], node, d)
+		}
+	}
+	return bytes.Equal(node, root)
+}
@@ -0,0 +1,105 @@
+/*
+ * Copyright © 2018 Lynn <lynn9388@gmail.com>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package sparse
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestTree_EmptyRoot(t *testing.T) {
+	a := New(SHA256)
+	b := New(SHA256)
+	if !bytes.Equal(a.Root(), b.Root()) {
+		t.Error("two empty trees should share the same root")
+	}
+}
+
+func TestTree_UpdateAndProve(t *testing.T) {
+	tree := New(SHA256)
+	tree.Update([]byte("alice"), []byte("100"))
+	tree.Update([]byte("bob"), []byte("200"))
+
+	proof, err := tree.Prove([]byte("alice"))
+	if err != nil {
+		t.Fatalf("Prove() error = %v", err)
+	}
+	if !tree.VerifyProof(tree.Root(), []byte("alice"), []byte("100"), proof) {
+		t.Error("VerifyProof() = false for a membership proof, want true")
+	}
+	if tree.VerifyProof(tree.Root(), []byte("alice"), []byte("999"), proof) {
+		t.Error("VerifyProof() = true for the wrong value, want false")
+	}
+}
+
+func TestTree_NonMembership(t *testing.T) {
+	tree := New(SHA256)
+	tree.Update([]byte("alice"), []byte("100"))
+
+	proof, err := tree.Prove([]byte("carol"))
+	if err != nil {
+		t.Fatalf("Prove() error = %v", err)
+	}
+	if !tree.VerifyProof(tree.Root(), []byte("carol"), nil, proof) {
+		t.Error("VerifyProof() = false for a non-membership proof, want true")
+	}
+	if tree.VerifyProof(tree.Root(), []byte("carol"), []byte("100"), proof) {
+		t.Error("VerifyProof() = true claiming a value for an absent key, want false")
+	}
+}
+
+func TestTree_Delete(t *testing.T) {
+	tree := New(SHA256)
+	empty := New(SHA256)
+
+	tree.Update([]byte("alice"), []byte("100"))
+	tree.Delete([]byte("alice"))
+
+	if !bytes.Equal(tree.Root(), empty.Root()) {
+		t.Error("deleting the only key should restore the empty root")
+	}
+}
+
+func TestTree_UpdateReclaimsStaleNodes(t *testing.T) {
+	tree := New(SHA256)
+	for i := 0; i < 10; i++ {
+		tree.Update([]byte("alice"), []byte{byte(i)})
+	}
+
+	if got, want := len(tree.nodes), tree.depth; got > want {
+		t.Errorf("len(nodes) = %d after 10 updates to one key, want at most %d (one per depth)", got, want)
+	}
+
+	tree.Delete([]byte("alice"))
+	if got := len(tree.nodes); got != 0 {
+		t.Errorf("len(nodes) = %d after deleting the only key, want 0", got)
+	}
+}
+
+func TestTree_UpdateOverwrite(t *testing.T) {
+	tree := New(SHA256)
+	tree.Update([]byte("alice"), []byte("100"))
+	tree.Update([]byte("alice"), []byte("200"))
+
+	proof, _ := tree.Prove([]byte("alice"))
+	if !tree.VerifyProof(tree.Root(), []byte("alice"), []byte("200"), proof) {
+		t.Error("VerifyProof() = false after overwrite, want true")
+	}
+	if tree.VerifyProof(tree.Root(), []byte("alice"), []byte("100"), proof) {
+		t.Error("VerifyProof() = true for the stale value after overwrite, want false")
+	}
+}